@@ -0,0 +1,89 @@
+// Command sts runs a subset of the NIST SP 800-22 statistical tests on a random
+// source and reports the P-value of each test.
+//
+// Usage:
+//
+//	sts [-n bits] [-src rand|int|file] [-intbits k]
+//
+// The rand source reads from rand.Reader. The int source concatenates the k-bit
+// values returned by rand.Int(2^k). Any other source is the name of a file to read,
+// with - meaning standard input.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/mmussomele/crypto/internal/sts"
+	"github.com/mmussomele/crypto/rand"
+)
+
+func main() {
+	n := flag.Int("n", 1000000, "number of bits to test")
+	src := flag.String("src", "rand", "random source: rand, int or a file name")
+	k := flag.Int("intbits", 13, "bits per value for the int source")
+	flag.Parse()
+
+	e, err := read(*src, *n, *k)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sts: %v\n", err)
+		os.Exit(2)
+	}
+
+	results, err := sts.Suite(e)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sts: %v\n", err)
+		os.Exit(2)
+	}
+
+	status := 0
+	for _, r := range results {
+		verdict := "PASS"
+		if !r.Passed() {
+			verdict = "FAIL"
+			status = 1
+		}
+		fmt.Printf("%-20s %f %s\n", r.Name, r.P, verdict)
+	}
+	os.Exit(status)
+}
+
+func read(src string, n, k int) ([]byte, error) {
+	switch src {
+	case "rand":
+		return sts.ReadBits(rand.Reader(), n)
+	case "int":
+		return intBits(n, k)
+	case "-":
+		return sts.ReadBits(os.Stdin, n)
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return sts.ReadBits(f, n)
+}
+
+// intBits collects n bits from values sampled uniformly from [0, 2^k) by rand.Int.
+func intBits(n, k int) ([]byte, error) {
+	if k < 1 {
+		return nil, errors.New("intbits must be positive")
+	}
+	max := new(big.Int).Lsh(big.NewInt(1), uint(k))
+	e := make([]byte, 0, n+k)
+	for len(e) < n {
+		v, err := rand.Int(max)
+		if err != nil {
+			return nil, err
+		}
+		for i := k - 1; i >= 0; i-- {
+			e = append(e, byte(v.Bit(i)))
+		}
+	}
+	return e[:n], nil
+}
@@ -0,0 +1,456 @@
+// Package sts implements a subset of the NIST SP 800-22 Rev. 1a statistical test
+// suite for random number generators.
+//
+// Every test takes a sequence of bits, one bit per byte, and returns the P-value of
+// the sequence under the hypothesis that it is random. A sequence fails a test if
+// its P-value is below the chosen significance level, usually Alpha. Sequences
+// shorter than SP 800-22 recommends for a test are rejected with ErrTooShort.
+package sts
+
+import (
+	"errors"
+	"io"
+	"math"
+)
+
+// Alpha is the significance level recommended by SP 800-22.
+const Alpha = 0.01
+
+// ErrTooShort is returned when a sequence has too few bits for a test.
+var ErrTooShort = errors.New("sts: sequence too short")
+
+// minBits is the minimum sequence length recommended for most tests.
+const minBits = 100
+
+// ReadBits reads n bits from r, most significant bit of each byte first.
+func ReadBits(r io.Reader, n int) ([]byte, error) {
+	buf := make([]byte, (n+7)/8)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	e := make([]byte, n)
+	for i := range e {
+		e[i] = (buf[i/8] >> uint(7-i%8)) & 1
+	}
+	return e, nil
+}
+
+// Frequency performs the frequency (monobit) test of section 2.1. The sequence must
+// be at least 100 bits long.
+func Frequency(e []byte) (float64, error) {
+	if len(e) < minBits {
+		return 0, ErrTooShort
+	}
+	return frequency(e), nil
+}
+
+func frequency(e []byte) float64 {
+	var s int
+	for _, b := range e {
+		s += 2*int(b) - 1
+	}
+	obs := math.Abs(float64(s)) / math.Sqrt(float64(len(e)))
+	return math.Erfc(obs / math.Sqrt2)
+}
+
+// BlockFrequency performs the frequency test within blocks of m bits of section 2.2.
+// The sequence must be at least 100 bits long and hold at least one block.
+func BlockFrequency(e []byte, m int) (float64, error) {
+	if m < 1 {
+		panic("sts: block length must be positive")
+	}
+	if len(e) < minBits || len(e) < m {
+		return 0, ErrTooShort
+	}
+	return blockFrequency(e, m), nil
+}
+
+func blockFrequency(e []byte, m int) float64 {
+	n := len(e) / m
+
+	var sum float64
+	for i := 0; i < n; i++ {
+		var ones int
+		for _, b := range e[i*m : (i+1)*m] {
+			ones += int(b)
+		}
+		v := float64(ones)/float64(m) - 0.5
+		sum += v * v
+	}
+	chi2 := 4 * float64(m) * sum
+	return Igamc(float64(n)/2, chi2/2)
+}
+
+// Runs performs the runs test of section 2.3. The sequence must be at least 100 bits
+// long.
+func Runs(e []byte) (float64, error) {
+	if len(e) < minBits {
+		return 0, ErrTooShort
+	}
+	return runs(e), nil
+}
+
+func runs(e []byte) float64 {
+	n := float64(len(e))
+
+	var ones int
+	for _, b := range e {
+		ones += int(b)
+	}
+	pi := float64(ones) / n
+
+	// The test is only applicable if the frequency test would pass.
+	if math.Abs(pi-0.5) >= 2/math.Sqrt(n) {
+		return 0
+	}
+
+	v := 1
+	for i := 1; i < len(e); i++ {
+		if e[i] != e[i-1] {
+			v++
+		}
+	}
+
+	num := math.Abs(float64(v) - 2*n*pi*(1-pi))
+	den := 2 * math.Sqrt(2*n) * pi * (1 - pi)
+	return math.Erfc(num / den)
+}
+
+// Parameters of the longest run test, indexed by the block length.
+var longestRunParams = []struct {
+	n, m int       // minimum sequence length and block length
+	lo   int       // longest run counted in the first class
+	pi   []float64 // class probabilities
+}{
+	{n: 750000, m: 10000, lo: 10, pi: []float64{0.0882, 0.2092, 0.2483, 0.1933, 0.1208, 0.0675, 0.0727}},
+	{n: 6272, m: 128, lo: 4, pi: []float64{0.1174, 0.2430, 0.2493, 0.1752, 0.1027, 0.1124}},
+	{n: 128, m: 8, lo: 1, pi: []float64{0.2148, 0.3672, 0.2305, 0.1875}},
+}
+
+// LongestRun performs the test for the longest run of ones in a block of section 2.4.
+// The sequence must be at least 128 bits long.
+func LongestRun(e []byte) (float64, error) {
+	for _, p := range longestRunParams {
+		if len(e) < p.n {
+			continue
+		}
+
+		k := len(p.pi) - 1
+		v := make([]int, len(p.pi))
+		n := len(e) / p.m
+		for i := 0; i < n; i++ {
+			var run, longest int
+			for _, b := range e[i*p.m : (i+1)*p.m] {
+				if b == 0 {
+					run = 0
+					continue
+				}
+				run++
+				if run > longest {
+					longest = run
+				}
+			}
+
+			c := longest - p.lo
+			switch {
+			case c < 0:
+				c = 0
+			case c > k:
+				c = k
+			}
+			v[c]++
+		}
+
+		var chi2 float64
+		for i, pi := range p.pi {
+			exp := float64(n) * pi
+			d := float64(v[i]) - exp
+			chi2 += d * d / exp
+		}
+		return Igamc(float64(k)/2, chi2/2), nil
+	}
+	return 0, ErrTooShort
+}
+
+// Serial performs the serial test of section 2.11 with overlapping patterns of m
+// bits. The sequence must be long enough that m < log2(len(e)) - 2.
+func Serial(e []byte, m int) (p1, p2 float64, err error) {
+	if m < 1 {
+		panic("sts: pattern length must be positive")
+	}
+	if m >= log2(len(e))-2 {
+		return 0, 0, ErrTooShort
+	}
+	p1, p2 = serial(e, m)
+	return p1, p2, nil
+}
+
+func serial(e []byte, m int) (p1, p2 float64) {
+	psi := func(m int) float64 {
+		if m <= 0 {
+			return 0
+		}
+		var sum float64
+		for _, c := range patterns(e, m) {
+			sum += float64(c) * float64(c)
+		}
+		n := float64(len(e))
+		return math.Ldexp(sum, m)/n - n
+	}
+
+	p0, pm1, pm2 := psi(m), psi(m-1), psi(m-2)
+	d1 := p0 - pm1
+	d2 := p0 - 2*pm1 + pm2
+	p1 = Igamc(math.Ldexp(1, m-2), d1/2)
+	p2 = Igamc(math.Ldexp(1, m-3), d2/2)
+	return p1, p2
+}
+
+// ApproximateEntropy performs the approximate entropy test of section 2.12 with
+// overlapping patterns of m bits. The sequence must be long enough that
+// m < log2(len(e)) - 5.
+func ApproximateEntropy(e []byte, m int) (float64, error) {
+	if m < 1 {
+		panic("sts: pattern length must be positive")
+	}
+	if m >= log2(len(e))-5 {
+		return 0, ErrTooShort
+	}
+	return approximateEntropy(e, m), nil
+}
+
+func approximateEntropy(e []byte, m int) float64 {
+	n := float64(len(e))
+	phi := func(m int) float64 {
+		if m == 0 {
+			return 0
+		}
+		var sum float64
+		for _, c := range patterns(e, m) {
+			if c > 0 {
+				p := float64(c) / n
+				sum += p * math.Log(p)
+			}
+		}
+		return sum
+	}
+
+	apEn := phi(m) - phi(m+1)
+	chi2 := 2 * n * (math.Ln2 - apEn)
+	return Igamc(math.Ldexp(1, m-1), chi2/2)
+}
+
+// CumulativeSums performs the cumulative sums test of section 2.13, in the forward
+// direction unless reverse is set. The sequence must be at least 100 bits long.
+func CumulativeSums(e []byte, reverse bool) (float64, error) {
+	if len(e) < minBits {
+		return 0, ErrTooShort
+	}
+	return cumulativeSums(e, reverse), nil
+}
+
+func cumulativeSums(e []byte, reverse bool) float64 {
+	var s, z int
+	for i := range e {
+		b := e[i]
+		if reverse {
+			b = e[len(e)-1-i]
+		}
+		s += 2*int(b) - 1
+		if s > z {
+			z = s
+		} else if -s > z {
+			z = -s
+		}
+	}
+
+	// The summation bounds truncate towards zero, as in the reference implementation.
+	n := len(e)
+	sn := math.Sqrt(float64(n))
+	fz := float64(z)
+
+	var sum1, sum2 float64
+	for k := (-n/z + 1) / 4; k <= (n/z-1)/4; k++ {
+		sum1 += normal(float64(4*k+1)*fz/sn) - normal(float64(4*k-1)*fz/sn)
+	}
+	for k := (-n/z - 3) / 4; k <= (n/z-1)/4; k++ {
+		sum2 += normal(float64(4*k+3)*fz/sn) - normal(float64(4*k+1)*fz/sn)
+	}
+	return 1 - sum1 + sum2
+}
+
+// Result is the outcome of a single test of a Suite.
+type Result struct {
+	Name string
+	P    float64
+}
+
+// Passed reports whether the result is significant at level Alpha.
+func (r Result) Passed() bool {
+	return r.P >= Alpha
+}
+
+// Suite runs every test with the parameters recommended by SP 800-22 for a sequence
+// of len(e) bits. The sequence must be at least 128 bits long.
+func Suite(e []byte) ([]Result, error) {
+	n := len(e)
+	longest, err := LongestRun(e)
+	if err != nil {
+		return nil, err
+	}
+
+	// Block length such that M >= 20, M > n/100 and there are fewer than 100 blocks,
+	// as recommended by section 2.2.7.
+	bm := n/100 + 1
+	if bm < 20 {
+		bm = 20
+	}
+
+	// Pattern lengths must satisfy m < log2(n)-2 (serial) and m < log2(n)-5
+	// (approximate entropy).
+	sm, am := 16, 10
+	if sm > log2(n)-3 {
+		sm = log2(n) - 3
+	}
+	if am > log2(n)-6 {
+		am = log2(n) - 6
+	}
+
+	// LongestRun already checked that every test has enough bits.
+	s1, s2 := serial(e, sm)
+	return []Result{
+		{"Frequency", frequency(e)},
+		{"BlockFrequency", blockFrequency(e, bm)},
+		{"Runs", runs(e)},
+		{"LongestRun", longest},
+		{"Serial", s1},
+		{"Serial", s2},
+		{"ApproximateEntropy", approximateEntropy(e, am)},
+		{"CumulativeSums", cumulativeSums(e, false)},
+		{"CumulativeSums", cumulativeSums(e, true)},
+	}, nil
+}
+
+// log2 returns the floor of the base 2 logarithm of n > 0, and -1 for n = 0.
+func log2(n int) int {
+	l := -1
+	for ; n > 0; n >>= 1 {
+		l++
+	}
+	return l
+}
+
+// patterns counts the occurrences of every m-bit pattern in e, wrapping around at
+// the end of the sequence.
+func patterns(e []byte, m int) []int {
+	c := make([]int, 1<<uint(m))
+	mask := 1<<uint(m) - 1
+
+	var w int
+	for i := 0; i < m-1; i++ {
+		w = w<<1 | int(e[i])
+	}
+	for i := range e {
+		w = (w<<1 | int(e[(i+m-1)%len(e)])) & mask
+		c[w]++
+	}
+	return c
+}
+
+// normal is the standard normal cumulative distribution function.
+func normal(x float64) float64 {
+	return math.Erfc(-x/math.Sqrt2) / 2
+}
+
+const (
+	machEp = 1.11022302462515654042e-16
+	maxLog = 7.09782712893383996843e2
+	big    = 4.503599627370496e15
+	bigInv = 2.22044604925031308085e-16
+)
+
+// Igamc is the regularized upper incomplete gamma function Q(a, x). It is the
+// survival function of a chi-squared distribution with 2a degrees of freedom
+// evaluated at 2x.
+func Igamc(a, x float64) float64 {
+	if x <= 0 || a <= 0 {
+		return 1
+	}
+	if x < 1 || x < a {
+		return 1 - igam(a, x)
+	}
+
+	ax := igamFactor(a, x)
+	if ax == 0 {
+		return 0
+	}
+
+	// Continued fraction expansion.
+	y := 1 - a
+	z := x + y + 1
+	c := 0.0
+	pkm2, qkm2 := 1.0, x
+	pkm1, qkm1 := x+1, z*x
+	ans := pkm1 / qkm1
+	for {
+		c++
+		y++
+		z += 2
+		yc := y * c
+		pk := pkm1*z - pkm2*yc
+		qk := qkm1*z - qkm2*yc
+
+		t := 1.0
+		if qk != 0 {
+			r := pk / qk
+			t = math.Abs((ans - r) / r)
+			ans = r
+		}
+
+		pkm2, pkm1 = pkm1, pk
+		qkm2, qkm1 = qkm1, qk
+		if math.Abs(pk) > big {
+			pkm2 *= bigInv
+			pkm1 *= bigInv
+			qkm2 *= bigInv
+			qkm1 *= bigInv
+		}
+		if t <= machEp {
+			return ans * ax
+		}
+	}
+}
+
+// igam is the regularized lower incomplete gamma function P(a, x).
+func igam(a, x float64) float64 {
+	if x <= 0 || a <= 0 {
+		return 0
+	}
+	if x > 1 && x > a {
+		return 1 - Igamc(a, x)
+	}
+
+	ax := igamFactor(a, x)
+	if ax == 0 {
+		return 0
+	}
+
+	// Power series.
+	r, c, ans := a, 1.0, 1.0
+	for c/ans > machEp {
+		r++
+		c *= x / r
+		ans += c
+	}
+	return ans * ax / a
+}
+
+// igamFactor computes x^a e^-x / Γ(a), or 0 if it underflows.
+func igamFactor(a, x float64) float64 {
+	lg, _ := math.Lgamma(a)
+	ax := a*math.Log(x) - x - lg
+	if ax < -maxLog {
+		return 0
+	}
+	return math.Exp(ax)
+}
@@ -0,0 +1,142 @@
+package sts
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+// epsilon is the first 100 bits of the binary expansion of pi, used by most of the
+// worked examples in SP 800-22.
+const epsilon = "1100100100001111110110101010001000100001011010001100001000110100110001001100011001100010100010111000"
+
+func TestFrequency(t *testing.T) {
+	assertP(t, "Frequency", frequency(bits("1011010101")), 0.527089)
+	assertP(t, "Frequency", frequency(bits(epsilon)), 0.109599)
+}
+
+func TestBlockFrequency(t *testing.T) {
+	assertP(t, "BlockFrequency", blockFrequency(bits("0110011010"), 3), 0.801252)
+	assertP(t, "BlockFrequency", blockFrequency(bits(epsilon), 10), 0.706438)
+}
+
+func TestRuns(t *testing.T) {
+	assertP(t, "Runs", runs(bits("1001101011")), 0.147232)
+	assertP(t, "Runs", runs(bits(epsilon)), 0.500798)
+}
+
+func TestLongestRun(t *testing.T) {
+	e := bits("11001100000101010110110001001100111000000000001001001101010100010001001111010110100000001101011111001100111001101101100010110010")
+	p, err := LongestRun(e)
+	if err != nil {
+		t.Fatalf("Failed to run test: %v", err)
+	}
+	// The published P-value of 0.180609 was computed from a rounded statistic.
+	assertP(t, "LongestRun", p, 0.180598)
+
+	if _, err := LongestRun(e[:127]); err != ErrTooShort {
+		t.Fatalf("Expected ErrTooShort, got %v", err)
+	}
+}
+
+func TestSerial(t *testing.T) {
+	p1, p2 := serial(bits("0011011101"), 3)
+	assertP(t, "Serial", p1, 0.808792)
+	assertP(t, "Serial", p2, 0.670320)
+}
+
+func TestApproximateEntropy(t *testing.T) {
+	assertP(t, "ApproximateEntropy", approximateEntropy(bits("0100110101"), 3), 0.261961)
+	assertP(t, "ApproximateEntropy", approximateEntropy(bits(epsilon), 2), 0.235301)
+}
+
+func TestCumulativeSums(t *testing.T) {
+	assertP(t, "CumulativeSums", cumulativeSums(bits("1011010111"), false), 0.4116588)
+	assertP(t, "CumulativeSums", cumulativeSums(bits(epsilon), false), 0.219194)
+	assertP(t, "CumulativeSums", cumulativeSums(bits(epsilon), true), 0.114866)
+}
+
+func TestTooShort(t *testing.T) {
+	// The worked examples above are shorter than the recommended minimum lengths.
+	short := bits(epsilon[:99])
+	for _, e := range [][]byte{nil, make([]byte, 99), short} {
+		for name, test := range map[string]func() (float64, error){
+			"Frequency":      func() (float64, error) { return Frequency(e) },
+			"BlockFrequency": func() (float64, error) { return BlockFrequency(e, 10) },
+			"Runs":           func() (float64, error) { return Runs(e) },
+			"Serial": func() (float64, error) {
+				p, _, err := Serial(e, 4)
+				return p, err
+			},
+			"ApproximateEntropy": func() (float64, error) { return ApproximateEntropy(e, 2) },
+			"CumulativeSums":     func() (float64, error) { return CumulativeSums(e, false) },
+		} {
+			if _, err := test(); err != ErrTooShort {
+				t.Fatalf("Expected ErrTooShort from %s for %d bits, got %v", name, len(e), err)
+			}
+		}
+	}
+
+	e := bits(epsilon)
+	if _, err := BlockFrequency(e, 101); err != ErrTooShort {
+		t.Fatalf("Expected ErrTooShort for a block longer than the sequence, got %v", err)
+	}
+	if _, _, err := Serial(e, 4); err != ErrTooShort {
+		t.Fatalf("Expected ErrTooShort for 4 bit serial patterns, got %v", err)
+	}
+	if _, err := ApproximateEntropy(e, 1); err != ErrTooShort {
+		t.Fatalf("Expected ErrTooShort for 1 bit entropy patterns, got %v", err)
+	}
+
+	// A constant sequence is long enough, and only fails.
+	for i := range e {
+		e[i] = 0
+	}
+	for _, f := range []func([]byte) (float64, error){Frequency, Runs, func(e []byte) (float64, error) { return CumulativeSums(e, true) }} {
+		if p, err := f(e); err != nil || p >= Alpha {
+			t.Fatalf("Expected a constant sequence to fail, got P = %f (%v)", p, err)
+		}
+	}
+}
+
+func TestReadBits(t *testing.T) {
+	e, err := ReadBits(bytes.NewReader([]byte{0xa5, 0xf0}), 12)
+	if err != nil {
+		t.Fatalf("Failed to read bits: %v", err)
+	}
+	if exp := bits("101001011111"); !bytes.Equal(e, exp) {
+		t.Fatalf("Expected %v, got %v", exp, e)
+	}
+}
+
+func TestSuite(t *testing.T) {
+	// A constant sequence must fail every test.
+	e := make([]byte, 1<<12)
+	for i := range e {
+		e[i] = 1
+	}
+	results, err := Suite(e)
+	if err != nil {
+		t.Fatalf("Failed to run suite: %v", err)
+	}
+	for _, r := range results {
+		if r.Passed() {
+			t.Fatalf("Constant sequence passed %s test with P = %f", r.Name, r.P)
+		}
+	}
+}
+
+func bits(s string) []byte {
+	e := make([]byte, len(s))
+	for i := range s {
+		e[i] = s[i] - '0'
+	}
+	return e
+}
+
+func assertP(t *testing.T, name string, actual, exp float64) {
+	t.Helper()
+	if math.Abs(actual-exp) > 1e-6 {
+		t.Fatalf("Expected %s P-value %f, got %f", name, exp, actual)
+	}
+}
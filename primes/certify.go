@@ -0,0 +1,205 @@
+package primes
+
+import (
+	"context"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+)
+
+// ErrNotCertified is returned by Certify when it can not prove that a number is
+// prime.
+var ErrNotCertified = errors.New("crypto/primes: unable to certify primality")
+
+const (
+	// rhoIterations bounds the work spent splitting each composite part of N-1.
+	rhoIterations = 1 << 16
+
+	// maxWitness bounds the search for a witness in Certify.
+	maxWitness = 1 << 12
+)
+
+// Certify constructs a certificate proving that p is prime. It factors p-1 with
+// trial division and Pollard's rho method and recursively certifies the prime
+// factors it finds, so it succeeds for every prime up to about 2^64 and for larger
+// primes when enough of p-1 factors into small primes. It returns ErrNotCertified
+// if p is not prime or if too little of p-1 could be factored.
+func Certify(p *big.Int) (*Certificate, error) {
+	c := certify(p)
+	if c == nil {
+		return nil, ErrNotCertified
+	}
+	return c, nil
+}
+
+// certify returns a certificate for n, or nil if it can not construct one.
+func certify(n *big.Int) *Certificate {
+	if n.BitLen() <= 32 {
+		if !trialDivision(n) {
+			return nil
+		}
+		return &Certificate{N: new(big.Int).Set(n)}
+	}
+	if n.Sign() <= 0 || !BailliePSW(n) {
+		return nil
+	}
+
+	// Factors that can not be certified themselves are left in the unfactored part.
+	n1 := new(big.Int).Sub(n, one)
+	r := new(big.Int).Set(n1)
+	f := big.NewInt(1)
+	m := new(big.Int)
+	var factors []*Certificate
+	for _, q := range partialFactors(n1) {
+		c := certify(q)
+		if c == nil {
+			continue
+		}
+		factors = append(factors, c)
+		for m.Mod(r, q).Sign() == 0 {
+			r.Div(r, q)
+			f.Mul(f, q)
+		}
+	}
+	if m.Mul(f, f).Mul(m, f).Cmp(n) < 0 {
+		return nil
+	}
+
+	a, e, g := new(big.Int), new(big.Int), new(big.Int)
+	for w := int64(2); w < maxWitness; w++ {
+		a.SetInt64(w)
+		if e.Exp(a, n1, n).Cmp(one) != 0 {
+			return nil // a Fermat witness; BailliePSW was wrong
+		}
+		ok := true
+		for _, c := range factors {
+			e.Div(n1, c.N)
+			g.Exp(a, e, n).Sub(g, one)
+			if g.GCD(nil, nil, g, n).Cmp(one) != 0 {
+				ok = false
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		c := &Certificate{N: new(big.Int).Set(n), A: a, Factors: factors}
+		if VerifyCertificate(c) != nil {
+			return nil // only possible for a composite n
+		}
+		return c
+	}
+	return nil
+}
+
+// partialFactors returns the distinct probable prime factors of n > 0 found with
+// trial division and Pollard's rho method.
+func partialFactors(n *big.Int) []*big.Int {
+	var qs []*big.Int
+	r := new(big.Int).Set(n)
+	if z := r.TrailingZeroBits(); z > 0 {
+		qs = append(qs, big.NewInt(2))
+		r.Rsh(r, z)
+	}
+
+	res := make([]uint32, len(sievePrimes))
+	residues(res, r, sievePrimes)
+	q, m := new(big.Int), new(big.Int)
+	for i, p := range sievePrimes {
+		if res[i] != 0 {
+			continue
+		}
+		q.SetUint64(uint64(p))
+		qs = append(qs, new(big.Int).Set(q))
+		for m.Mod(r, q).Sign() == 0 {
+			r.Div(r, q)
+		}
+	}
+
+	rest := []*big.Int{r}
+	for len(rest) > 0 {
+		x := rest[len(rest)-1]
+		rest = rest[:len(rest)-1]
+		switch {
+		case x.Cmp(one) == 0:
+		case BailliePSW(x):
+			if !containsInt(qs, x) {
+				qs = append(qs, x)
+			}
+		default:
+			if d := pollardRho(context.Background(), x, rhoIterations); d != nil {
+				rest = append(rest, d, new(big.Int).Div(x, d))
+			}
+		}
+	}
+	return qs
+}
+
+func containsInt(xs []*big.Int, x *big.Int) bool {
+	for _, y := range xs {
+		if y.Cmp(x) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+type asnCertificate struct {
+	N       *big.Int
+	A       *big.Int
+	Factors []asnCertificate
+}
+
+func (c *Certificate) toASN1() asnCertificate {
+	a := c.A
+	if a == nil {
+		a = new(big.Int)
+	}
+	asnc := asnCertificate{N: c.N, A: a, Factors: []asnCertificate{}}
+	for _, fc := range c.Factors {
+		asnc.Factors = append(asnc.Factors, fc.toASN1())
+	}
+	return asnc
+}
+
+func (asnc *asnCertificate) fromASN1() *Certificate {
+	c := &Certificate{N: asnc.N, A: asnc.A}
+	if c.A.Sign() == 0 {
+		c.A = nil
+	}
+	for i := range asnc.Factors {
+		c.Factors = append(c.Factors, asnc.Factors[i].fromASN1())
+	}
+	return c
+}
+
+// Marshal encodes the Certificate in ASN.1 DER format as
+//
+//	Certificate ::= SEQUENCE {
+//	    n       INTEGER,
+//	    a       INTEGER, -- 0 if n is proven prime by trial division
+//	    factors SEQUENCE OF Certificate
+//	}
+func (c *Certificate) Marshal() []byte {
+	b, err := asn1.Marshal(c.toASN1())
+	if err != nil {
+		panic(err) // should never fail
+	}
+	return b
+}
+
+// Unmarshal attempts to parse a certificate from the bytes. It does not verify the
+// certificate.
+func (c *Certificate) Unmarshal(b []byte) error {
+	var asnc asnCertificate
+	rest, err := asn1.Unmarshal(b, &asnc)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return asn1.SyntaxError{Msg: "trailing data"}
+	}
+	*c = *asnc.fromASN1()
+	return nil
+}
@@ -0,0 +1,160 @@
+package primes
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+func TestCertifySmall(t *testing.T) {
+	for _, s := range []string{
+		"2", "3", "65537", "2147483647", "4294967311", "2305843009213693951",
+		"18446744073709551557", "170141183460469231731687303715884105727",
+	} {
+		p, _ := new(big.Int).SetString(s, 10)
+		assertCertified(t, p)
+	}
+
+	for i := 0; i < 20; i++ {
+		p, err := Find(64, 20)
+		if err != nil {
+			t.Fatalf("Failed to find prime: %v", err)
+		}
+		assertCertified(t, p)
+	}
+}
+
+func TestCertifyComposite(t *testing.T) {
+	for _, s := range []string{
+		"0", "1", "-7", "561", "4294967297", "3825123056546413051",
+		"318665857834031151167461", // a strong pseudoprime to the first 12 prime bases
+	} {
+		n, _ := new(big.Int).SetString(s, 10)
+		if _, err := Certify(n); err != ErrNotCertified {
+			t.Fatalf("Expected ErrNotCertified for %s, got %v", n, err)
+		}
+	}
+}
+
+func TestCertifyPratt(t *testing.T) {
+	// p-1 is a product of small primes, so it factors completely.
+	m := smoothNumber(500)
+	p := new(big.Int)
+	for k := int64(1); ; k++ {
+		p.Mul(m, big.NewInt(2*k)).Add(p, one)
+		if p.ProbablyPrime(20) {
+			break
+		}
+	}
+
+	c := assertCertified(t, p)
+	f := factoredPart(c)
+	if f.Add(f, one).Cmp(p) != 0 {
+		t.Fatalf("Expected p-1 to be factored completely")
+	}
+}
+
+func TestCertifyBLS(t *testing.T) {
+	// p-1 = 2mR for a large prime R, so only about 0.4 of it can be factored.
+	r, err := Find(300, 20)
+	if err != nil {
+		t.Fatalf("Failed to find prime: %v", err)
+	}
+	m := smoothNumber(210)
+	m.Mul(m, r)
+	p := new(big.Int)
+	for k := int64(1); ; k++ {
+		p.Mul(m, big.NewInt(2*k)).Add(p, one)
+		if p.ProbablyPrime(20) {
+			break
+		}
+	}
+
+	c := assertCertified(t, p)
+	f := factoredPart(c)
+	if f.Mul(f, f).Cmp(p) > 0 {
+		t.Skip("R was certified, so Pocklington's theorem applies")
+	}
+
+	bad := *c
+	bad.N = new(big.Int).Add(p, two)
+	assertInvalid(t, &bad)
+
+	bad = *c
+	bad.Factors = c.Factors[:1]
+	assertInvalid(t, &bad)
+}
+
+func TestCertificateMarshal(t *testing.T) {
+	_, c, err := FindProvable(512)
+	if err != nil {
+		t.Fatalf("Failed to generate provable prime: %v", err)
+	}
+	p, _ := new(big.Int).SetString("18446744073709551557", 10)
+	c2, err := Certify(p)
+	if err != nil {
+		t.Fatalf("Failed to certify prime: %v", err)
+	}
+
+	for _, c := range []*Certificate{c, c2, {N: big.NewInt(7)}} {
+		b := c.Marshal()
+		var u Certificate
+		if err := u.Unmarshal(b); err != nil {
+			t.Fatalf("Failed to unmarshal certificate: %v", err)
+		}
+		if err := VerifyCertificate(&u); err != nil {
+			t.Fatalf("Failed to verify unmarshalled certificate: %v", err)
+		}
+		if !bytes.Equal(u.Marshal(), b) {
+			t.Fatalf("Certificate did not round trip")
+		}
+		if err := u.Unmarshal(append(b, 0)); err == nil {
+			t.Fatalf("Expected an error for trailing data")
+		}
+	}
+}
+
+func BenchmarkCertify64(b *testing.B) {
+	p, _ := new(big.Int).SetString("18446744073709551557", 10)
+	for i := 0; i < b.N; i++ {
+		Certify(p)
+	}
+}
+
+// smoothNumber returns a product of small primes of at least bits bits.
+func smoothNumber(bits int) *big.Int {
+	m := big.NewInt(1)
+	for i := 0; m.BitLen() < bits; i++ {
+		m.Mul(m, big.NewInt(int64(sievePrimes[(i*7919)%len(sievePrimes)])))
+	}
+	return m
+}
+
+// factoredPart returns the part of c.N-1 that c factors.
+func factoredPart(c *Certificate) *big.Int {
+	r := new(big.Int).Sub(c.N, one)
+	f := big.NewInt(1)
+	m := new(big.Int)
+	for _, fc := range c.Factors {
+		for m.Mod(r, fc.N).Sign() == 0 {
+			r.Div(r, fc.N)
+			f.Mul(f, fc.N)
+		}
+	}
+	return f
+}
+
+func assertCertified(t *testing.T, p *big.Int) *Certificate {
+	t.Helper()
+	c, err := Certify(p)
+	if err != nil {
+		t.Fatalf("Failed to certify %s: %v", p, err)
+	}
+	if c.N.Cmp(p) != 0 {
+		t.Fatalf("Certificate is for %s, not %s", c.N, p)
+	}
+	if err := VerifyCertificate(c); err != nil {
+		t.Fatalf("Failed to verify certificate for %s: %v", p, err)
+	}
+	return c
+}
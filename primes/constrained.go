@@ -0,0 +1,230 @@
+package primes
+
+import (
+	"math/big"
+
+	"github.com/mmussomele/crypto/rand"
+)
+
+// enumerateLimit is the largest number of candidates FindConstrained tests one by
+// one instead of sampling them.
+const enumerateLimit = 1 << 12
+
+// FindConstrained finds a prime p in [lo, hi) with p = a (mod m), chosen uniformly at
+// random from all such primes. The probability that the returned number is not prime
+// is at most 2^(-n). m must be positive. It returns ErrNoPrime if there is no such
+// prime.
+//
+// Candidates are sampled independently, and those with small factors are rejected by
+// trial division before running a primality test. Ranges with at most 4096
+// candidates are enumerated. If sampling from a larger range fails many more times
+// than expected, the range may contain no such prime, so it is scanned from a random
+// starting point instead, and the first prime is returned.
+func FindConstrained(lo, hi, a, m *big.Int, n int) (*big.Int, error) {
+	return FindConstrainedWith(Is, lo, hi, a, m, n)
+}
+
+// FindConstrainedWith is like FindConstrained, but uses the primality test t.
+func FindConstrainedWith(t Test, lo, hi, a, m *big.Int, n int) (*big.Int, error) {
+	if m.Sign() <= 0 {
+		panic("crypto/primes: modulus must be positive")
+	}
+	if lo.Cmp(two) < 0 {
+		lo = two
+	}
+
+	// The candidates are base+k*m for k in [0, count).
+	base := new(big.Int).Sub(a, lo)
+	base.Mod(base, m).Add(base, lo)
+	if base.Cmp(hi) >= 0 {
+		return nil, ErrNoPrime
+	}
+	count := new(big.Int).Sub(hi, base)
+	count.Sub(count, one).Div(count, m).Add(count, one)
+
+	// Every candidate is divisible by g = gcd(a, m), so only g can be prime.
+	g := new(big.Int).Mod(a, m)
+	if g.GCD(nil, nil, g, m); g.Cmp(one) != 0 {
+		d := new(big.Int).Sub(g, base)
+		if g.Cmp(base) < 0 || g.Cmp(hi) >= 0 || d.Mod(d, m).Sign() != 0 {
+			return nil, ErrNoPrime
+		}
+		switch ok, err := t(g, n); {
+		case err != nil:
+			return nil, err
+		case !ok:
+			return nil, ErrNoPrime
+		}
+		return g, nil
+	}
+
+	if count.Cmp(big.NewInt(enumerateLimit)) <= 0 {
+		var ps []*big.Int
+		for k := int64(0); k < count.Int64(); k++ {
+			c := new(big.Int).SetInt64(k)
+			c.Mul(c, m).Add(c, base)
+			switch ok, err := isCandidatePrime(t, c, n); {
+			case err != nil:
+				return nil, err
+			case ok:
+				ps = append(ps, c)
+			}
+		}
+		if len(ps) == 0 {
+			return nil, ErrNoPrime
+		}
+		i, err := rand.Int(big.NewInt(int64(len(ps))))
+		if err != nil {
+			return nil, err
+		}
+		return ps[i.Int64()], nil
+	}
+
+	// About one in ln(hi) candidates is prime, so sampling is bounded by a multiple
+	// of ln(hi) < 0.7*hi.BitLen() draws.
+	for draws := 12 * hi.BitLen(); draws > 0; draws-- {
+		c, err := rand.Int(count)
+		if err != nil {
+			return nil, err
+		}
+		c.Mul(c, m).Add(c, base)
+		switch ok, err := isCandidatePrime(t, c, n); {
+		case err != nil:
+			return nil, err
+		case ok:
+			return c, nil
+		}
+	}
+	return scanConstrained(t, base, m, count, n)
+}
+
+// scanConstrained tests the candidates base+k*m for k in [0, count) in order, starting
+// from a random k and wrapping around, and returns the first prime.
+func scanConstrained(t Test, base, m, count *big.Int, n int) (*big.Int, error) {
+	k, err := rand.Int(count)
+	if err != nil {
+		return nil, err
+	}
+	c := new(big.Int).Mul(k, m)
+	c.Add(c, base)
+	end := new(big.Int).Mul(count, m)
+	end.Add(end, base)
+
+	for i := new(big.Int); i.Cmp(count) < 0; i.Add(i, one) {
+		switch ok, err := isCandidatePrime(t, c, n); {
+		case err != nil:
+			return nil, err
+		case ok:
+			return c, nil
+		}
+		if c.Add(c, m).Cmp(end) >= 0 {
+			c.Set(base)
+		}
+	}
+	return nil, ErrNoPrime
+}
+
+// isCandidatePrime reports whether c >= 2 is prime, rejecting even numbers and those
+// with small factors before running t.
+func isCandidatePrime(t Test, c *big.Int, n int) (bool, error) {
+	if c.Bit(0) == 0 {
+		return c.Cmp(two) == 0, nil
+	}
+	if hasSmallFactor(c, sievePrimes[:trialPrimes]) {
+		return false, nil
+	}
+	return t(c, n)
+}
+
+// FindInRange finds a prime in [lo, hi), chosen uniformly at random from all primes in
+// the range. The probability that the returned number is not prime is at most
+// 2^(-n). It returns ErrNoPrime if there is no prime in the range.
+func FindInRange(lo, hi *big.Int, n int) (*big.Int, error) {
+	return FindConstrained(lo, hi, one, one, n)
+}
+
+// FindUniform finds a prime of exactly b bits, chosen uniformly at random from all
+// such primes. Unlike Find, which returns the prime following a random starting point
+// and so favours primes after large gaps, every prime is equally likely. The
+// probability that the returned number is not prime is at most 2^(-n). b must be at
+// least 2.
+func FindUniform(b, n int) (*big.Int, error) {
+	return FindUniformWith(Is, b, n)
+}
+
+// FindUniformWith is like FindUniform, but uses the primality test t.
+func FindUniformWith(t Test, b, n int) (*big.Int, error) {
+	if b < 2 {
+		panic("crypto/primes: primes must have at least 2 bits")
+	}
+	lo := new(big.Int).Lsh(one, uint(b-1))
+	hi := new(big.Int).Lsh(one, uint(b))
+	return FindConstrainedWith(t, lo, hi, one, one, n)
+}
+
+// FindBlum finds a random prime p = 3 (mod 4) of exactly b bits, as used by Blum
+// integers and the Rabin cryptosystem. The probability that the returned number is
+// not prime is at most 2^(-n). b must be at least 2.
+func FindBlum(b, n int) (*big.Int, error) {
+	if b < 2 {
+		panic("crypto/primes: Blum primes must have at least 2 bits")
+	}
+	lo := new(big.Int).Lsh(one, uint(b-1))
+	hi := new(big.Int).Lsh(one, uint(b))
+	return FindConstrained(lo, hi, three, big.NewInt(4), n)
+}
+
+// FindStrong finds a random strong prime p of exactly b bits as defined by ANSI X9.31:
+// p-1 and p+1 have prime factors of at least 101, 141, 171 or 201 bits for b below
+// 1024, 1536, 2048 and above, as in FIPS 186-5 Appendix A.1. p is at least
+// sqrt(2)*2^(b-1), so the product of two such primes has exactly 2b bits. The
+// probability that the returned number or the auxiliary primes are not prime is at
+// most 2^(-n). b must be at least 256.
+func FindStrong(b, n int) (*big.Int, error) {
+	p, _, _, err := findStrong(b, n)
+	return p, err
+}
+
+// findStrong returns a strong prime p of b bits, and the prime factors p1 of p-1 and
+// p2 of p+1.
+func findStrong(b, n int) (p, p1, p2 *big.Int, err error) {
+	if b < 256 {
+		panic("crypto/primes: strong primes must have at least 256 bits")
+	}
+	aux := 201
+	switch {
+	case b < 1024:
+		aux = 101
+	case b < 1536:
+		aux = 141
+	case b < 2048:
+		aux = 171
+	}
+
+	// lo = ceil(sqrt(2^(2b-1)))
+	lo := new(big.Int).Lsh(one, uint(2*b-1))
+	lo.Sqrt(lo).Add(lo, one)
+	hi := new(big.Int).Lsh(one, uint(b))
+	for {
+		if p1, err = Find(aux, n); err != nil {
+			return nil, nil, nil, err
+		}
+		if p2, err = Find(aux, n); err != nil {
+			return nil, nil, nil, err
+		}
+		if p1.Cmp(p2) == 0 {
+			continue
+		}
+
+		// p = 1 (mod p1) and p = -1 (mod p2).
+		r, m, err := CRT([]*big.Int{one, new(big.Int).Sub(p2, one)}, []*big.Int{p1, p2})
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		p, err = FindConstrained(lo, hi, r, m, n)
+		if err == ErrNoPrime {
+			continue
+		}
+		return p, p1, p2, err
+	}
+}
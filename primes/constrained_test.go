@@ -0,0 +1,292 @@
+package primes
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestFindConstrained(t *testing.T) {
+	lo := new(big.Int).Lsh(one, 255)
+	hi := new(big.Int).Lsh(one, 256)
+	for _, c := range []struct{ a, m int64 }{{1, 1}, {3, 4}, {1, 4}, {-1, 65537}, {12345, 1000003}} {
+		a, m := big.NewInt(c.a), big.NewInt(c.m)
+		for i := 0; i < 5; i++ {
+			p, err := FindConstrained(lo, hi, a, m, 20)
+			if err != nil {
+				t.Fatalf("Failed to find prime: %v", err)
+			}
+			assertConstrained(t, p, lo, hi, a, m)
+		}
+	}
+}
+
+func TestFindConstrainedSmall(t *testing.T) {
+	// The primes in [100, 200) which are 1 (mod 3) should be found about equally
+	// often, and no other numbers.
+	want := []int64{103, 109, 127, 139, 151, 157, 163, 181, 193, 199}
+	counts := make(map[int64]int)
+	lo, hi, a, m := big.NewInt(100), big.NewInt(200), big.NewInt(1), big.NewInt(3)
+	const draws = 1000
+	for i := 0; i < draws; i++ {
+		p, err := FindConstrained(lo, hi, a, m, 20)
+		if err != nil {
+			t.Fatalf("Failed to find prime: %v", err)
+		}
+		counts[p.Int64()]++
+	}
+	if len(counts) != len(want) {
+		t.Fatalf("Expected the primes %v, got %v", want, counts)
+	}
+	for _, p := range want {
+		if c := counts[p]; c < draws/len(want)/2 || c > 2*draws/len(want) {
+			t.Fatalf("Found %d %d times out of %d", p, c, draws)
+		}
+	}
+
+	for _, c := range []struct{ lo, hi, a, m, p int64 }{
+		{0, 10, 3, 9, 3},    // gcd(a, m) = 3 is prime
+		{0, 3, 0, 1, 2},     // 2 is the only prime
+		{-100, 3, 2, 4, 2},  // negative lower bound
+		{0, 100, 7, 100, 7}, // a single candidate
+	} {
+		p, err := FindConstrained(big.NewInt(c.lo), big.NewInt(c.hi), big.NewInt(c.a), big.NewInt(c.m), 20)
+		if err != nil || p.Int64() != c.p {
+			t.Fatalf("Expected %d in [%d, %d) = %d (mod %d), got %v (%v)", c.p, c.lo, c.hi, c.a, c.m, p, err)
+		}
+	}
+
+	for _, c := range []struct{ lo, hi, a, m int64 }{
+		{0, 10, 6, 9},       // gcd(a, m) = 3 is not a candidate
+		{10, 20, 4, 8},      // gcd(a, m) = 4 is not prime
+		{24, 29, 1, 1},      // no prime in the range
+		{100, 50, 1, 1},     // empty range
+		{114, 127, 1, 2},    // odd numbers without a prime
+		{0, 1000, 15, 1000}, // every candidate is divisible by 5
+	} {
+		if _, err := FindConstrained(big.NewInt(c.lo), big.NewInt(c.hi), big.NewInt(c.a), big.NewInt(c.m), 20); err != ErrNoPrime {
+			t.Fatalf("Expected ErrNoPrime for [%d, %d) = %d (mod %d), got %v", c.lo, c.hi, c.a, c.m, err)
+		}
+	}
+}
+
+func TestFindConstrainedPrimeFree(t *testing.T) {
+	// Build a range of 4500 candidates base+4k which are all 3 (mod 4) and composite,
+	// by choosing for each trial division prime p in turn the residue of k that
+	// covers the most candidates not yet known to be composite, and solving for base
+	// with the CRT.
+	const count = 4500
+	m := big.NewInt(4)
+	rs := []*big.Int{big.NewInt(3)}
+	ms := []*big.Int{m}
+	covered := make([]bool, count)
+	left := count
+	for _, p := range sievePrimes[:trialPrimes] {
+		if left == 0 {
+			break
+		}
+		hits := make([]int, p)
+		for k, c := range covered {
+			if !c {
+				hits[k%int(p)]++
+			}
+		}
+		r := 0
+		for i := range hits {
+			if hits[i] > hits[r] {
+				r = i
+			}
+		}
+		for k := r; k < count; k += int(p) {
+			if !covered[k] {
+				covered[k] = true
+				left--
+			}
+		}
+		// base+4r = 0 (mod p)
+		bp := big.NewInt(int64(p))
+		res := big.NewInt(int64(-4 * r))
+		rs = append(rs, res.Mod(res, bp))
+		ms = append(ms, bp)
+	}
+	if left != 0 {
+		t.Fatalf("Failed to cover %d candidates", left)
+	}
+	base, _, err := CRT(rs, ms)
+	if err != nil {
+		t.Fatalf("Failed to solve congruences: %v", err)
+	}
+
+	a := big.NewInt(3)
+	hi := new(big.Int).Add(base, big.NewInt(4*count))
+	if _, err := FindConstrained(base, hi, a, m, 20); err != ErrNoPrime {
+		t.Fatalf("Expected ErrNoPrime for a prime-free range, got %v", err)
+	}
+
+	// The scan finds the only prime in a range from any starting point.
+	for i := 0; i < 20; i++ {
+		p, err := scanConstrained(Is, big.NewInt(24), one, big.NewInt(6), 20)
+		if err != nil || p.Int64() != 29 {
+			t.Fatalf("Expected 29, got %v (%v)", p, err)
+		}
+	}
+}
+
+func TestFindInRange(t *testing.T) {
+	for _, r := range [][2]int64{{1000000, 1000100}, {1 << 40, 1<<40 + 1<<20}} {
+		lo, hi := big.NewInt(r[0]), big.NewInt(r[1])
+		for i := 0; i < 20; i++ {
+			p, err := FindInRange(lo, hi, 20)
+			if err != nil {
+				t.Fatalf("Failed to find prime: %v", err)
+			}
+			assertConstrained(t, p, lo, hi, one, one)
+		}
+	}
+}
+
+func TestFindUniform(t *testing.T) {
+	for _, b := range []int{2, 3, 17, 256} {
+		p, err := FindUniform(b, 20)
+		if err != nil {
+			t.Fatalf("Failed to find prime: %v", err)
+		}
+		if p.BitLen() != b || !p.ProbablyPrime(20) {
+			t.Fatalf("Expected a %d bit prime, got %s", b, p)
+		}
+	}
+}
+
+func TestFindUniformGaps(t *testing.T) {
+	// Find is more likely to return primes following large gaps, while FindUniform
+	// should return primes with the gap distribution of all 20 bit primes.
+	const draws = 4000
+	lo, hi := uint64(1<<19), uint64(1<<20)
+	uniform := make([]uint64, draws)
+	incremental := make([]uint64, draws)
+	for i := range uniform {
+		p, err := FindUniform(20, 20)
+		if err != nil {
+			t.Fatalf("Failed to find prime: %v", err)
+		}
+		uniform[i] = p.Uint64()
+
+		for incremental[i] == 0 {
+			p, err := FindNext(new(big.Int).SetUint64(lo+uint64(r.Int63n(int64(hi-lo)))), 20)
+			if err != nil {
+				t.Fatalf("Failed to find prime: %v", err)
+			}
+			if p.Uint64() < hi {
+				incremental[i] = p.Uint64()
+			}
+		}
+	}
+
+	// The critical value of the chi-squared distribution with 9 degrees of freedom
+	// at significance level 10^-4.
+	const critical = 33.72
+	if x := gapChiSquared(lo, hi, uniform); x > critical {
+		t.Fatalf("FindUniform gaps have chi-squared statistic %.2f > %.2f", x, critical)
+	}
+	if x := gapChiSquared(lo, hi, incremental); x < critical {
+		t.Fatalf("Expected the biased incremental search to fail, got chi-squared statistic %.2f", x)
+	}
+}
+
+// gapChiSquared returns the chi-squared statistic of the gaps preceding the primes ps
+// in [lo, hi), compared to the gaps preceding all primes in the range.
+func gapChiSquared(lo, hi uint64, ps []uint64) float64 {
+	bin := func(gap uint64) int {
+		switch {
+		case gap <= 14:
+			return int(gap/2) - 1
+		case gap <= 18:
+			return 7
+		case gap <= 24:
+			return 8
+		}
+		return 9
+	}
+
+	gaps := make(map[uint64]uint64)
+	var total [10]float64
+	prev := uint64(0)
+	for it := NewIterator(lo-1000, hi); it.Next(); {
+		if p := it.Prime(); p >= lo {
+			gaps[p] = p - prev
+			total[bin(p-prev)]++
+		}
+		prev = it.Prime()
+	}
+	var observed [10]float64
+	for _, p := range ps {
+		observed[bin(gaps[p])]++
+	}
+
+	x := 0.0
+	for i := range total {
+		e := total[i] / float64(len(gaps)) * float64(len(ps))
+		x += (observed[i] - e) * (observed[i] - e) / e
+	}
+	return x
+}
+
+func TestFindBlum(t *testing.T) {
+	for _, b := range []int{2, 3, 16, 512} {
+		p, err := FindBlum(b, 20)
+		if err != nil {
+			t.Fatalf("Failed to find Blum prime: %v", err)
+		}
+		if p.BitLen() != b {
+			t.Fatalf("Expected a %d bit prime, got %d bits", b, p.BitLen())
+		}
+		assertConstrained(t, p, one, new(big.Int).Lsh(one, uint(b)), three, big.NewInt(4))
+	}
+}
+
+func TestFindStrong(t *testing.T) {
+	for _, c := range []struct{ bits, aux int }{{256, 101}, {1024, 141}} {
+		p, p1, p2, err := findStrong(c.bits, 20)
+		if err != nil {
+			t.Fatalf("Failed to find strong prime: %v", err)
+		}
+		if p.BitLen() != c.bits || !p.ProbablyPrime(20) {
+			t.Fatalf("Expected a %d bit prime, got %s", c.bits, p)
+		}
+		lower := new(big.Int).Lsh(one, uint(2*c.bits-1))
+		if new(big.Int).Mul(p, p).Cmp(lower) < 0 {
+			t.Fatalf("Expected p >= sqrt(2)*2^%d", c.bits-1)
+		}
+
+		m := new(big.Int)
+		for _, f := range []struct {
+			q *big.Int
+			d int64
+		}{{p1, -1}, {p2, 1}} {
+			if f.q.BitLen() < c.aux || !f.q.ProbablyPrime(20) {
+				t.Fatalf("Expected an auxiliary prime of at least %d bits, got %s", c.aux, f.q)
+			}
+			if m.Add(p, big.NewInt(f.d)).Mod(m, f.q).Sign() != 0 {
+				t.Fatalf("%s does not divide p%+d", f.q, f.d)
+			}
+		}
+	}
+}
+
+func BenchmarkFindStrong1024(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		FindStrong(1024, 20)
+	}
+}
+
+func assertConstrained(t *testing.T, p, lo, hi, a, m *big.Int) {
+	t.Helper()
+	if p.Cmp(lo) < 0 || p.Cmp(hi) >= 0 {
+		t.Fatalf("Prime %s is not in [%s, %s)", p, lo, hi)
+	}
+	if d := new(big.Int).Sub(p, a); d.Mod(d, m).Sign() != 0 {
+		t.Fatalf("Prime %s is not %s (mod %s)", p, a, m)
+	}
+	if !p.ProbablyPrime(20) {
+		t.Fatalf("%s is not prime", p)
+	}
+}
@@ -0,0 +1,252 @@
+package primes
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/mmussomele/crypto/rand"
+)
+
+// ErrNoLog is returned when h is not a power of g.
+var ErrNoLog = errors.New("crypto/primes: no discrete logarithm")
+
+const (
+	// bsgsBits is the largest prime order for which PohligHellman uses
+	// BabyStepGiantStep rather than PollardRhoLog.
+	bsgsBits = 24
+
+	// rhoLogAttempts is the number of random walks PollardRhoLog tries before
+	// giving up.
+	rhoLogAttempts = 32
+)
+
+// DiscreteLog returns the least x >= 0 with g^x = h (mod p) for a prime p. It
+// factors p-1 with Factor to find the order of g, and then uses PohligHellman. It
+// returns ErrNoLog if h is not a power of g, and the error from Factor if it can not
+// factor p-1.
+func DiscreteLog(ctx context.Context, g, h, p *big.Int) (*big.Int, error) {
+	if p.Cmp(two) < 0 {
+		panic("crypto/primes: discrete logarithms require a modulus greater than 1")
+	}
+	fs, err := Factor(ctx, new(big.Int).Sub(p, one), nil)
+	if err != nil {
+		return nil, err
+	}
+	g = new(big.Int).Mod(g, p)
+	if g.Sign() == 0 {
+		return nil, ErrNoLog
+	}
+
+	// Remove the primes from the factorization of p-1 that g^((p-1)/q) = 1 shows are
+	// not needed for the order.
+	n := new(big.Int).Sub(p, one)
+	t, m := new(big.Int), new(big.Int)
+	var order []PrimePower
+	for _, f := range fs {
+		k := f.K
+		for ; k > 0; k-- {
+			t.Div(n, f.P)
+			if m.Exp(g, t, p).Cmp(one) != 0 {
+				break
+			}
+			n.Set(t)
+		}
+		if k > 0 {
+			order = append(order, PrimePower{P: f.P, K: k})
+		}
+	}
+	return PohligHellman(ctx, g, h, p, order)
+}
+
+// PohligHellman returns the least x >= 0 with g^x = h (mod p), where g has order n
+// modulo p with the factorization fs. It reduces the problem to logarithms in the
+// subgroups of prime order q dividing n, which are found with BabyStepGiantStep for
+// small q and PollardRhoLog otherwise. It returns ErrNoLog if h is not a power of g.
+func PohligHellman(ctx context.Context, g, h, p *big.Int, fs []PrimePower) (*big.Int, error) {
+	if p.Cmp(two) < 0 {
+		panic("crypto/primes: discrete logarithms require a modulus greater than 1")
+	}
+	n := big.NewInt(1)
+	for _, f := range fs {
+		n.Mul(n, new(big.Int).Exp(f.P, big.NewInt(int64(f.K)), nil))
+	}
+	g = new(big.Int).Mod(g, p)
+	h = new(big.Int).Mod(h, p)
+	gInv := new(big.Int).ModInverse(g, p)
+	if gInv == nil {
+		return nil, ErrNoLog
+	}
+
+	residues := make([]*big.Int, len(fs))
+	moduli := make([]*big.Int, len(fs))
+	c, e, t, g0, h0, gamma := new(big.Int), new(big.Int), new(big.Int), new(big.Int), new(big.Int), new(big.Int)
+	for i, f := range fs {
+		// g0 = g^(n/q^k) has order q^k, and gamma = g0^(q^(k-1)) has order q. The
+		// digits of x = log h0 in base q are the logarithms to the base gamma of
+		// (g0^-x*h0)^(q^(k-1-j)) for the digits x found so far.
+		qk := new(big.Int).Exp(f.P, big.NewInt(int64(f.K)), nil)
+		c.Div(n, qk)
+		g0.Exp(g, c, p)
+		h0.Exp(h, c, p)
+		gamma.Exp(g0, e.Div(qk, f.P), p)
+		g0Inv := new(big.Int).Exp(gInv, c, p)
+
+		x, qj := big.NewInt(0), big.NewInt(1)
+		for j := f.K - 1; j >= 0; j-- {
+			t.Exp(g0Inv, x, p).Mul(t, h0).Mod(t, p)
+			t.Exp(t, e.Exp(f.P, big.NewInt(int64(j)), nil), p)
+			d, err := primeOrderLog(ctx, gamma, t, p, f.P)
+			if err != nil {
+				return nil, err
+			}
+			x.Add(x, d.Mul(d, qj))
+			qj.Mul(qj, f.P)
+		}
+		residues[i], moduli[i] = x, qk
+	}
+
+	x, _, err := CRT(residues, moduli)
+	if err != nil {
+		return nil, err
+	}
+	if t.Exp(g, x, p).Cmp(h) != 0 {
+		return nil, ErrNoLog // n is not the order of g
+	}
+	return x, nil
+}
+
+// primeOrderLog returns the logarithm of h to the base g of prime order q.
+func primeOrderLog(ctx context.Context, g, h, p, q *big.Int) (*big.Int, error) {
+	if q.BitLen() <= bsgsBits {
+		return BabyStepGiantStep(ctx, g, h, p, q)
+	}
+	return PollardRhoLog(ctx, g, h, p, q)
+}
+
+// BabyStepGiantStep returns the least x in [0, n) with g^x = h (mod p) with Shanks'
+// baby-step giant-step algorithm, which takes time and memory proportional to the
+// square root of n. It returns ErrNoLog if there is no such x.
+func BabyStepGiantStep(ctx context.Context, g, h, p, n *big.Int) (*big.Int, error) {
+	if p.Cmp(two) < 0 || n.Sign() <= 0 {
+		panic("crypto/primes: discrete logarithms require a modulus greater than 1 and a positive bound")
+	}
+	g = new(big.Int).Mod(g, p)
+	h = new(big.Int).Mod(h, p)
+
+	// x = i*m + j for 0 <= i, j < m, so h*g^(-m*i) = g^j.
+	m := new(big.Int).Sub(n, one)
+	m.Sqrt(m).Add(m, one)
+	if !m.IsInt64() {
+		panic("crypto/primes: baby-step giant-step bound is too large")
+	}
+	steps := m.Int64()
+
+	baby := make(map[string]int64, steps)
+	y := big.NewInt(1)
+	for j := int64(0); j < steps; j++ {
+		if j%checkInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if _, ok := baby[string(y.Bytes())]; !ok {
+			baby[string(y.Bytes())] = j
+		}
+		y.Mul(y, g).Mod(y, p)
+	}
+
+	giant := new(big.Int).ModInverse(g, p)
+	if giant == nil {
+		return nil, ErrNoLog
+	}
+	giant.Exp(giant, m, p)
+	y.Set(h)
+	x := new(big.Int)
+	for i := int64(0); i < steps; i++ {
+		if i%checkInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if j, ok := baby[string(y.Bytes())]; ok {
+			x.SetInt64(i).Mul(x, m).Add(x, big.NewInt(j))
+			if x.Cmp(n) < 0 {
+				return x, nil
+			}
+			break
+		}
+		y.Mul(y, giant).Mod(y, p)
+	}
+	return nil, ErrNoLog
+}
+
+// PollardRhoLog returns x in [0, n) with g^x = h (mod p), where g has prime order n
+// modulo p, with Pollard's rho algorithm. It takes time proportional to the square
+// root of n but little memory. It returns ErrNoLog if h is not a power of g.
+func PollardRhoLog(ctx context.Context, g, h, p, n *big.Int) (*big.Int, error) {
+	if p.Cmp(two) < 0 || n.Sign() <= 0 {
+		panic("crypto/primes: discrete logarithms require a modulus greater than 1 and a positive order")
+	}
+	g = new(big.Int).Mod(g, p)
+	h = new(big.Int).Mod(h, p)
+	x := new(big.Int)
+	if h.Cmp(one) == 0 {
+		return x, nil
+	}
+	// h is in the subgroup generated by g iff h^n = 1, since n is prime.
+	if x.Exp(h, n, p).Cmp(one) != 0 || g.Cmp(one) == 0 {
+		return nil, ErrNoLog
+	}
+
+	// The walk multiplies by h, squares or multiplies by g depending on the low word
+	// of y mod 3, keeping y = g^a*h^b.
+	step := func(y, a, b *big.Int) {
+		switch y.Bits()[0] % 3 {
+		case 0:
+			y.Mul(y, h).Mod(y, p)
+			b.Add(b, one).Mod(b, n)
+		case 1:
+			y.Mul(y, y).Mod(y, p)
+			a.Lsh(a, 1).Mod(a, n)
+			b.Lsh(b, 1).Mod(b, n)
+		default:
+			y.Mul(y, g).Mod(y, p)
+			a.Add(a, one).Mod(a, n)
+		}
+	}
+
+	for attempt := 0; attempt < rhoLogAttempts; attempt++ {
+		a, err := rand.Int(n)
+		if err != nil {
+			return nil, err
+		}
+		b, err := rand.Int(n)
+		if err != nil {
+			return nil, err
+		}
+		y := new(big.Int).Exp(g, a, p)
+		y.Mul(y, x.Exp(h, b, p)).Mod(y, p)
+
+		// Floyd's cycle finding: the hare takes two steps for each of the tortoise's.
+		Y, A, B := new(big.Int).Set(y), new(big.Int).Set(a), new(big.Int).Set(b)
+		for i := 0; ; i++ {
+			if i%checkInterval == 0 && ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			step(y, a, b)
+			step(Y, A, B)
+			step(Y, A, B)
+			if y.Cmp(Y) == 0 {
+				break
+			}
+		}
+
+		// g^a*h^b = g^A*h^B, so x = (A-a)/(b-B) (mod n).
+		d := new(big.Int).Sub(b, B)
+		if d.ModInverse(d.Mod(d, n), n) == nil {
+			continue
+		}
+		x.Sub(A, a).Mul(x, d).Mod(x, n)
+		if y.Exp(g, x, p).Cmp(h) == 0 {
+			return x, nil
+		}
+	}
+	return nil, ErrNoLog
+}
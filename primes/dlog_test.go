@@ -0,0 +1,192 @@
+package primes
+
+import (
+	"context"
+	"math/big"
+	"testing"
+)
+
+func TestDiscreteLog(t *testing.T) {
+	ctx := context.Background()
+	for _, bits := range [][]int{{16, 16, 16}, {20, 24, 32}} {
+		p := smoothOrderPrime(t, bits)
+		g, err := PrimitiveRoot(ctx, p)
+		if err != nil {
+			t.Fatalf("Failed to find primitive root of %s: %v", p, err)
+		}
+
+		for i := 0; i < 5; i++ {
+			x := new(big.Int).Rand(r, new(big.Int).Sub(p, one))
+			h := new(big.Int).Exp(g, x, p)
+			y, err := DiscreteLog(ctx, g, h, p)
+			if err != nil {
+				t.Fatalf("Failed to find logarithm of %s to the base %s mod %s: %v", h, g, p, err)
+			}
+			if y.Cmp(x) != 0 {
+				t.Fatalf("Expected logarithm %s of %s to the base %s mod %s, got %s", x, h, g, p, y)
+			}
+		}
+
+		// g^2 generates the squares, so the logarithm is reduced modulo (p-1)/2 and
+		// non-squares have no logarithm.
+		g2 := new(big.Int).Mul(g, g)
+		x := new(big.Int).Rand(r, p)
+		y, err := DiscreteLog(ctx, g2, new(big.Int).Exp(g2, x, p), p)
+		if err != nil {
+			t.Fatalf("Failed to find logarithm to the base %s mod %s: %v", g2, p, err)
+		}
+		if x.Mod(x, new(big.Int).Rsh(p, 1)).Cmp(y) != 0 {
+			t.Fatalf("Expected logarithm %s to the base %s mod %s, got %s", x, g2, p, y)
+		}
+		if _, err := DiscreteLog(ctx, g2, g, p); err != ErrNoLog {
+			t.Fatalf("Expected ErrNoLog for a non-square, got %v", err)
+		}
+	}
+}
+
+func TestBabyStepGiantStep(t *testing.T) {
+	ctx := context.Background()
+	p, err := Find(64, 20)
+	if err != nil {
+		t.Fatalf("Failed to find prime: %v", err)
+	}
+	g := big.NewInt(3)
+	for _, n := range []int64{1, 2, 100, 1 << 20, 1<<20 + 1} {
+		bound := big.NewInt(n)
+		x := new(big.Int).Rand(r, bound)
+		h := new(big.Int).Exp(g, x, p)
+		y, err := BabyStepGiantStep(ctx, g, h, p, bound)
+		if err != nil {
+			t.Fatalf("Failed to find logarithm below %d: %v", n, err)
+		}
+		if y.Cmp(x) != 0 {
+			t.Fatalf("Expected logarithm %s, got %s", x, y)
+		}
+	}
+
+	h := new(big.Int).Exp(g, big.NewInt(1<<20), p)
+	if _, err := BabyStepGiantStep(ctx, g, h, p, big.NewInt(1<<20)); err != ErrNoLog {
+		t.Fatalf("Expected ErrNoLog for a logarithm above the bound, got %v", err)
+	}
+}
+
+func TestPollardRhoLog(t *testing.T) {
+	ctx := context.Background()
+	p, q, g := subgroup(t, 32, 1, 128)
+	for i := 0; i < 3; i++ {
+		x := new(big.Int).Rand(r, q)
+		h := new(big.Int).Exp(g, x, p)
+		y, err := PollardRhoLog(ctx, g, h, p, q)
+		if err != nil {
+			t.Fatalf("Failed to find logarithm of %s: %v", h, err)
+		}
+		if y.Cmp(x) != 0 {
+			t.Fatalf("Expected logarithm %s of %s, got %s", x, h, y)
+		}
+	}
+
+	if _, err := PollardRhoLog(ctx, g, big.NewInt(2), p, q); err != ErrNoLog {
+		t.Fatalf("Expected ErrNoLog outside the subgroup, got %v", err)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := PollardRhoLog(cctx, g, new(big.Int).Exp(g, big.NewInt(12345), p), p, q); err != context.Canceled {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestPohligHellman(t *testing.T) {
+	// The subgroup of order q^3 needs a logarithm in the subgroup of order q for each
+	// base q digit.
+	p, q, g := subgroup(t, 20, 3, 128)
+	fs := []PrimePower{{P: q, K: 3}}
+	n := new(big.Int).Exp(q, big.NewInt(3), nil)
+	for i := 0; i < 5; i++ {
+		x := new(big.Int).Rand(r, n)
+		y, err := PohligHellman(context.Background(), g, new(big.Int).Exp(g, x, p), p, fs)
+		if err != nil {
+			t.Fatalf("Failed to find logarithm: %v", err)
+		}
+		if y.Cmp(x) != 0 {
+			t.Fatalf("Expected logarithm %s, got %s", x, y)
+		}
+	}
+
+	p = smoothOrderPrime(t, []int{16, 16})
+	g, err := PrimitiveRoot(context.Background(), p)
+	if err != nil {
+		t.Fatalf("Failed to find primitive root of %s: %v", p, err)
+	}
+	wrong := []PrimePower{{P: big.NewInt(2), K: 1}}
+	if _, err := PohligHellman(context.Background(), g, new(big.Int).Mul(g, g), p, wrong); err != ErrNoLog {
+		t.Fatalf("Expected ErrNoLog for the wrong order, got %v", err)
+	}
+}
+
+func BenchmarkDiscreteLog(b *testing.B) {
+	p, _ := new(big.Int).SetString("18446744073709551557", 10)
+	g, _ := PrimitiveRoot(context.Background(), p)
+	h := big.NewInt(123456789)
+	for i := 0; i < b.N; i++ {
+		DiscreteLog(context.Background(), g, h, p)
+	}
+}
+
+// smoothOrderPrime returns a prime p such that p-1 is 2k times primes of the given
+// sizes for a small k.
+func smoothOrderPrime(t *testing.T, bits []int) *big.Int {
+	t.Helper()
+	m := big.NewInt(2)
+	for _, b := range bits {
+		q, err := Find(b, 20)
+		if err != nil {
+			t.Fatalf("Failed to find prime: %v", err)
+		}
+		m.Mul(m, q)
+	}
+	p := new(big.Int)
+	for k := int64(1); ; k++ {
+		p.Mul(m, big.NewInt(k)).Add(p, one)
+		ok, err := Is(p, 20)
+		if err != nil {
+			t.Fatalf("Failed to check primality: %v", err)
+		}
+		if ok {
+			return p
+		}
+	}
+}
+
+// subgroup returns a prime p of at least pBits bits, a prime q of qBits bits such
+// that q^e divides p-1, and a generator g of the subgroup of order q^e.
+func subgroup(t *testing.T, qBits, e, pBits int) (p, q, g *big.Int) {
+	t.Helper()
+	q, err := Find(qBits, 20)
+	if err != nil {
+		t.Fatalf("Failed to find prime: %v", err)
+	}
+	n := new(big.Int).Exp(q, big.NewInt(int64(e)), nil)
+	k := new(big.Int).Lsh(one, uint(pBits-n.BitLen()))
+	p = new(big.Int)
+	for ; ; k.Add(k, two) {
+		p.Mul(n, k).Add(p, one)
+		ok, err := Is(p, 20)
+		if err != nil {
+			t.Fatalf("Failed to check primality: %v", err)
+		}
+		if ok {
+			break
+		}
+	}
+
+	// a^k has order dividing q^e, and exactly q^e unless a^(k*q^(e-1)) = 1.
+	g, t1 := new(big.Int), new(big.Int)
+	m := new(big.Int).Div(n, q)
+	for a := int64(2); ; a++ {
+		g.Exp(big.NewInt(a), k, p)
+		if t1.Exp(g, m, p).Cmp(one) != 0 {
+			return p, q, g
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package primes
+
+import (
+	"math"
+	"math/big"
+)
+
+const (
+	// segmentSize is the number of odd numbers in a segment of an Iterator, chosen so
+	// that a segment fits in the L1 cache.
+	segmentSize = 1 << 15
+
+	// maxSievePrime bounds the primes an Iterator sieves with. Candidates above its
+	// square that survive the sieve are checked with BailliePSW, which is
+	// deterministic below 2^64.
+	maxSievePrime = 1 << 20
+)
+
+// An Iterator enumerates the primes in a range [lo, hi) in increasing order with a
+// segmented sieve of Eratosthenes. It is used as follows:
+//
+//	it := primes.NewIterator(lo, hi)
+//	for it.Next() {
+//		p := it.Prime()
+//		...
+//	}
+type Iterator struct {
+	hi   uint64
+	next uint64 // the first number of the next segment
+	done bool   // there is no next segment
+	two  bool   // 2 is yet to be returned
+
+	base uint64 // the first number of the current segment
+	comp []bool // comp[i] reports whether base+2i is composite
+	i    int    // the next index of comp to check
+	p    uint64
+
+	ps    []uint32  // the odd sieving primes
+	mults []uint64  // mults[j] is the next odd multiple of ps[j] to mark
+	more  *Iterator // the sieving primes above 2^16
+}
+
+// NewIterator returns an Iterator for the primes in [lo, hi).
+func NewIterator(lo, hi uint64) *Iterator {
+	it := &Iterator{
+		hi:   hi,
+		two:  lo <= 2 && hi > 2,
+		comp: make([]bool, 0, segmentSize),
+	}
+	if lo < 3 {
+		lo = 3
+	}
+	it.next = lo | 1
+	it.base = it.next
+
+	// The primes below 2^16 are enough for ranges below 2^32. Larger ranges extend
+	// them as needed.
+	it.ps = sievePrimes[:len(sievePrimes):len(sievePrimes)]
+	it.mults = make([]uint64, len(it.ps))
+	for j, q := range it.ps {
+		it.mults[j] = it.firstMultiple(uint64(q))
+	}
+	return it
+}
+
+// Next advances the Iterator to the next prime, which is then returned by Prime. It
+// returns false when there are no more primes in the range.
+func (it *Iterator) Next() bool {
+	if it.two {
+		it.two = false
+		it.p = 2
+		return true
+	}
+	for {
+		for ; it.i < len(it.comp); it.i++ {
+			if it.comp[it.i] {
+				continue
+			}
+			v := it.base + 2*uint64(it.i)
+			if v >= maxSievePrime*maxSievePrime && !BailliePSW(new(big.Int).SetUint64(v)) {
+				continue
+			}
+			it.p = v
+			it.i++
+			return true
+		}
+		if !it.fill() {
+			return false
+		}
+	}
+}
+
+// Prime returns the prime found by the last call to Next.
+func (it *Iterator) Prime() uint64 {
+	return it.p
+}
+
+// fill sieves the next segment. It returns false if there is none.
+func (it *Iterator) fill() bool {
+	if it.done || it.next >= it.hi {
+		it.done = true
+		return false
+	}
+
+	// The segment holds the odd numbers in [base, min(hi, base+2*segmentSize)).
+	it.base = it.next
+	n := uint64(segmentSize)
+	if odd := (it.hi - it.base + 1) / 2; odd <= n {
+		n = odd
+		it.done = true
+	} else {
+		it.next = it.base + 2*n
+	}
+	it.comp = it.comp[:n]
+	for i := range it.comp {
+		it.comp[i] = false
+	}
+	it.i = 0
+
+	last := it.base + 2*(n-1)
+	it.extend(last)
+	for j, q := range it.ps {
+		m, step := it.mults[j], 2*uint64(q)
+		for ; m <= last; m += step {
+			it.comp[(m-it.base)/2] = true
+			if m > math.MaxUint64-step {
+				m = math.MaxUint64 // no more multiples below 2^64
+				break
+			}
+		}
+		it.mults[j] = m
+	}
+	return true
+}
+
+// extend adds the sieving primes up to the square root of last.
+func (it *Iterator) extend(last uint64) {
+	for q := uint64(it.ps[len(it.ps)-1]); q*q <= last; {
+		if it.more == nil {
+			it.more = NewIterator(1<<16, maxSievePrime)
+		}
+		if !it.more.Next() {
+			return
+		}
+		q = it.more.Prime()
+		it.ps = append(it.ps, uint32(q))
+		it.mults = append(it.mults, it.firstMultiple(q))
+	}
+}
+
+// firstMultiple returns the first odd multiple of q that is at least max(base, q^2),
+// or the largest uint64 if there is none.
+func (it *Iterator) firstMultiple(q uint64) uint64 {
+	if q*q >= it.base {
+		return q * q
+	}
+	m := it.base
+	if r := m % q; r != 0 {
+		m += q - r
+	}
+	if m%2 == 0 {
+		m += q
+	}
+	if m < it.base {
+		return math.MaxUint64
+	}
+	return m
+}
+
+// MaxPrimePi is the largest argument of PrimePi, for which it allocates 256 MiB.
+const MaxPrimePi = 1 << 48
+
+// PrimePi returns the number of primes less than or equal to x with the
+// Lucy_Hedgehog algorithm, which takes time proportional to x^(3/4) and memory
+// proportional to x^(1/2). It panics if x is above MaxPrimePi.
+func PrimePi(x uint64) uint64 {
+	if x > MaxPrimePi {
+		panic("crypto/primes: PrimePi argument above MaxPrimePi")
+	}
+	if x < 2 {
+		return 0
+	}
+	r := uint64(math.Sqrt(float64(x)))
+	for r*r > x {
+		r--
+	}
+	for (r+1)*(r+1) <= x {
+		r++
+	}
+
+	// small[v] and large[i] are the counts of the numbers in [2, v] and [2, x/i]
+	// which are prime or have no prime factor below the current p. Sieving with p
+	// removes the numbers whose least prime factor is p.
+	small := make([]uint64, r+1)
+	large := make([]uint64, r+1)
+	for i := uint64(1); i <= r; i++ {
+		small[i] = i - 1
+		large[i] = x/i - 1
+	}
+	for p := uint64(2); p <= r; p++ {
+		if small[p] == small[p-1] {
+			continue // p is composite
+		}
+		sp, p2 := small[p-1], p*p
+		end := r
+		if x/p2 < end {
+			end = x / p2
+		}
+		for i := uint64(1); i <= end; i++ {
+			if d := i * p; d <= r {
+				large[i] -= large[d] - sp
+			} else {
+				large[i] -= small[x/d] - sp
+			}
+		}
+		for v := r; v >= p2; v-- {
+			small[v] -= small[v/p] - sp
+		}
+	}
+	return large[1]
+}
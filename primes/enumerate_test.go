@@ -0,0 +1,84 @@
+package primes
+
+import (
+	"math"
+	"math/big"
+	"sort"
+	"testing"
+)
+
+func TestIterator(t *testing.T) {
+	for _, r := range [][2]uint64{
+		{0, 0}, {0, 3}, {2, 3}, {3, 4}, {4, 5}, {10, 10}, {0, 100000},
+		{1000, 1000 + 6*segmentSize + 7},          // several segments
+		{1<<32 - 100000, 1<<32 + 100000},          // sieving primes above 2^16
+		{1<<40 - 100000, 1<<40 + 100000},          // candidates checked with BailliePSW
+		{math.MaxUint64 - 100000, math.MaxUint64}, // the top of the range
+	} {
+		it := NewIterator(r[0], r[1])
+		last := uint64(0)
+		for v := r[0]; v < r[1]; v++ {
+			if !new(big.Int).SetUint64(v).ProbablyPrime(0) {
+				continue
+			}
+			if !it.Next() {
+				t.Fatalf("Iterator over [%d, %d) stopped before %d", r[0], r[1], v)
+			}
+			if it.Prime() != v {
+				t.Fatalf("Expected prime %d in [%d, %d), got %d", v, r[0], r[1], it.Prime())
+			}
+			last = v
+		}
+		if it.Next() {
+			t.Fatalf("Iterator over [%d, %d) found %d after %d", r[0], r[1], it.Prime(), last)
+		}
+		if it.Next() {
+			t.Fatalf("Iterator over [%d, %d) continued after returning false", r[0], r[1])
+		}
+	}
+}
+
+func TestPrimePi(t *testing.T) {
+	for _, c := range []struct{ x, pi uint64 }{
+		{0, 0}, {1, 0}, {2, 1}, {3, 2}, {4, 2}, {100, 25}, {1000, 168},
+		{10000, 1229}, {100000, 9592}, {1000000, 78498}, {10000000, 664579},
+		{100000000, 5761455}, {1000000000, 50847534}, {10000000000, 455052511},
+		{100000000000, 4118054813}, {1 << 32, 203280221},
+	} {
+		if pi := PrimePi(c.x); pi != c.pi {
+			t.Fatalf("Expected pi(%d) = %d, got %d", c.x, c.pi, pi)
+		}
+	}
+
+	// Compare with the primes the Iterator finds.
+	var ps []uint64
+	for it := NewIterator(0, 100000); it.Next(); {
+		ps = append(ps, it.Prime())
+	}
+	for x := uint64(0); x < 100000; x += 997 {
+		count := sort.Search(len(ps), func(i int) bool { return ps[i] > x })
+		if pi := PrimePi(x); pi != uint64(count) {
+			t.Fatalf("Expected pi(%d) = %d, got %d", x, count, pi)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("Expected a panic above MaxPrimePi")
+		}
+	}()
+	PrimePi(MaxPrimePi + 1)
+}
+
+func BenchmarkIterator(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		for it := NewIterator(0, 10000000); it.Next(); {
+		}
+	}
+}
+
+func BenchmarkPrimePi(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		PrimePi(1000000000000)
+	}
+}
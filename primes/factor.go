@@ -0,0 +1,583 @@
+package primes
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"math/bits"
+	"sort"
+
+	"github.com/mmussomele/crypto/rand"
+)
+
+// ErrIncomplete is returned by Factor when it could not split every composite
+// factor within its effort bounds.
+var ErrIncomplete = errors.New("crypto/primes: factorization incomplete")
+
+// A PrimePower is a factor P^K of a factorization. P is prime, unless it is part of
+// an incomplete factorization.
+type PrimePower struct {
+	P *big.Int
+	K int
+}
+
+// FactorOptions bounds the effort spent by Factor on each composite factor. Zero
+// fields select the defaults, and negative fields disable the method they bound.
+type FactorOptions struct {
+	// TrialBound is the bound below which factors are found by trial division. It is
+	// at most 2^16, which is the default.
+	TrialBound int
+
+	// RhoIterations bounds the iterations of Pollard's rho method. The default is
+	// 2^18.
+	RhoIterations int
+
+	// PM1Bound and PM1Bound2 are the stage 1 and stage 2 bounds of Pollard's p-1
+	// method. The defaults are 20000 and 100*PM1Bound.
+	PM1Bound, PM1Bound2 int64
+
+	// ECMCurves is the number of curves tried by the elliptic curve method, with stage
+	// 1 and stage 2 bounds ECMBound and ECMBound2. The defaults are 20 curves, 2000
+	// and 100*ECMBound.
+	ECMCurves           int
+	ECMBound, ECMBound2 int64
+
+	// SieveBits is the size in bits up to which composite factors that the other
+	// methods can not split are factored with QuadraticSieve. The default is 200.
+	SieveBits int
+}
+
+// maxBound2 is the largest stage 2 bound.
+const maxBound2 = 1 << 32
+
+func (o *FactorOptions) withDefaults() FactorOptions {
+	var d FactorOptions
+	if o != nil {
+		d = *o
+	}
+	if d.TrialBound == 0 || d.TrialBound > 1<<16 {
+		d.TrialBound = 1 << 16
+	}
+	if d.RhoIterations == 0 {
+		d.RhoIterations = 1 << 18
+	}
+	if d.PM1Bound == 0 {
+		d.PM1Bound = 20000
+	}
+	if d.PM1Bound2 == 0 {
+		d.PM1Bound2 = 100 * d.PM1Bound
+	}
+	if d.ECMCurves == 0 {
+		d.ECMCurves = 20
+	}
+	if d.ECMBound == 0 {
+		d.ECMBound = 2000
+	}
+	if d.ECMBound2 == 0 {
+		d.ECMBound2 = 100 * d.ECMBound
+	}
+	if d.SieveBits == 0 {
+		d.SieveBits = 200
+	}
+	if d.PM1Bound2 > maxBound2 {
+		d.PM1Bound2 = maxBound2
+	}
+	if d.ECMBound2 > maxBound2 {
+		d.ECMBound2 = maxBound2
+	}
+	return d
+}
+
+// Factor returns the prime factorization of n, sorted by prime, using trial
+// division, Pollard's rho method in Brent's variant, Pollard's p-1 method,
+// Lenstra's elliptic curve method and the quadratic sieve, in that order. Factors
+// are proven prime for trial division and otherwise only checked with BailliePSW.
+//
+// If a composite factor can not be split within the bounds of opts, which may be
+// nil, Factor returns ErrIncomplete together with the factorization found, which
+// contains the composite factors. If ctx is done, it returns the context error in
+// the same way. n must be positive.
+func Factor(ctx context.Context, n *big.Int, opts *FactorOptions) ([]PrimePower, error) {
+	if n.Sign() <= 0 {
+		panic("crypto/primes: can only factor positive integers")
+	}
+	o := opts.withDefaults()
+
+	var fs []PrimePower
+	r := new(big.Int).Set(n)
+	if z := r.TrailingZeroBits(); z > 0 {
+		fs = append(fs, PrimePower{P: big.NewInt(2), K: int(z)})
+		r.Rsh(r, z)
+	}
+	fs, r = trialFactors(fs, r, o.TrialBound)
+
+	var err error
+	rest := []PrimePower{{P: r, K: 1}}
+	for len(rest) > 0 {
+		x := rest[len(rest)-1]
+		rest = rest[:len(rest)-1]
+		if x.P.Cmp(one) == 0 {
+			continue
+		}
+		if BailliePSW(x.P) {
+			fs = append(fs, x)
+			continue
+		}
+		if r, k := perfectPower(x.P, o.TrialBound); k > 1 {
+			rest = append(rest, PrimePower{P: r, K: x.K * k})
+			continue
+		}
+		if err == nil {
+			err = ctx.Err()
+		}
+		var d *big.Int
+		if err == nil {
+			d = split(ctx, x.P, &o)
+		}
+		if d == nil {
+			fs = append(fs, x)
+			if err == nil {
+				err = ctx.Err()
+			}
+			if err == nil {
+				err = ErrIncomplete
+			}
+			continue
+		}
+		rest = append(rest,
+			PrimePower{P: d, K: x.K},
+			PrimePower{P: new(big.Int).Div(x.P, d), K: x.K})
+	}
+	return mergeFactors(fs), err
+}
+
+// trialFactors appends the prime powers of n below bound to fs, and returns them
+// together with the remaining cofactor. n must be odd.
+func trialFactors(fs []PrimePower, n *big.Int, bound int) ([]PrimePower, *big.Int) {
+	ps := sievePrimes[:sort.Search(len(sievePrimes), func(i int) bool {
+		return int(sievePrimes[i]) >= bound
+	})]
+	res := make([]uint32, len(ps))
+	residues(res, n, ps)
+
+	q, m := new(big.Int), new(big.Int)
+	for i, p := range ps {
+		if res[i] != 0 {
+			continue
+		}
+		q.SetUint64(uint64(p))
+		k := 0
+		for m.Mod(n, q).Sign() == 0 {
+			n.Div(n, q)
+			k++
+		}
+		fs = append(fs, PrimePower{P: new(big.Int).Set(q), K: k})
+	}
+	return fs, n
+}
+
+// split returns a nontrivial factor of the composite n, or nil if it finds none
+// within the bounds of o.
+func split(ctx context.Context, n *big.Int, o *FactorOptions) *big.Int {
+	if o.RhoIterations > 0 {
+		if d := pollardRho(ctx, n, o.RhoIterations); d != nil {
+			return d
+		}
+	}
+	if o.PM1Bound > 0 && o.PM1Bound2 > 0 {
+		if d := pollardPM1(ctx, n, o.PM1Bound, o.PM1Bound2); d != nil {
+			return d
+		}
+	}
+	if o.ECMBound > 0 && o.ECMBound2 > 0 {
+		for i := 0; i < o.ECMCurves && ctx.Err() == nil; i++ {
+			if d := ecm(ctx, n, o.ECMBound, o.ECMBound2); d != nil {
+				return d
+			}
+		}
+	}
+	if n.BitLen() <= o.SieveBits {
+		if d, err := QuadraticSieve(ctx, n, 0); err == nil {
+			return d
+		}
+	}
+	return nil
+}
+
+// perfectPower returns r and k > 1 with n = r^k if the odd number n is a perfect
+// power, and n and 1 otherwise. n must have no factors below bound, which limits the
+// exponents to check.
+func perfectPower(n *big.Int, bound int) (*big.Int, int) {
+	if bound < 3 {
+		bound = 3
+	}
+	maxK := n.BitLen() / (bits.Len(uint(bound)) - 1)
+	r, t := new(big.Int), new(big.Int)
+	for k := 2; k <= maxK; k++ {
+		if k > 2 && k%2 == 0 {
+			continue // squares were already checked
+		}
+		if root(r, n, k); t.Exp(r, t.SetInt64(int64(k)), nil).Cmp(n) == 0 {
+			return r, k
+		}
+	}
+	return n, 1
+}
+
+// root sets z to the floor of the kth root of n > 0 with Newton's method and
+// returns z.
+func root(z, n *big.Int, k int) *big.Int {
+	bk := big.NewInt(int64(k))
+	bk1 := big.NewInt(int64(k - 1))
+	z.Lsh(one, uint((n.BitLen()+k-1)/k))
+	y, t := new(big.Int), new(big.Int)
+	for {
+		// y = ((k-1)z + n/z^(k-1)) / k
+		t.Exp(z, bk1, nil)
+		y.Div(n, t).Add(y, t.Mul(z, bk1)).Div(y, bk)
+		if y.Cmp(z) >= 0 {
+			return z
+		}
+		z.Set(y)
+	}
+}
+
+// mergeFactors sorts fs by P and combines the powers of equal factors.
+func mergeFactors(fs []PrimePower) []PrimePower {
+	sort.Slice(fs, func(i, j int) bool {
+		return fs[i].P.Cmp(fs[j].P) < 0
+	})
+	merged := fs[:0]
+	for _, f := range fs {
+		if l := len(merged); l > 0 && merged[l-1].P.Cmp(f.P) == 0 {
+			merged[l-1].K += f.K
+			continue
+		}
+		merged = append(merged, f)
+	}
+	return merged
+}
+
+// pollardRho returns a nontrivial factor of the composite n found with Brent's
+// variant of Pollard's rho method, or nil if it finds none in about limit
+// iterations or ctx is done.
+func pollardRho(ctx context.Context, n *big.Int, limit int) *big.Int {
+	const batch = 128
+	x, y, ys, q, d, g := new(big.Int), new(big.Int), new(big.Int), new(big.Int), new(big.Int), new(big.Int)
+	f := func(z, c *big.Int) {
+		z.Mul(z, z).Add(z, c).Mod(z, n)
+	}
+
+	for c := int64(1); limit > 0; c++ {
+		cc := big.NewInt(c)
+		y.SetInt64(2)
+		q.SetInt64(1)
+		g.SetInt64(1)
+		for r := 1; g.Cmp(one) == 0 && limit > 0; r *= 2 {
+			if ctx.Err() != nil {
+				return nil
+			}
+			x.Set(y)
+			for i := 0; i < r; i++ {
+				f(y, cc)
+			}
+			for k := 0; k < r && g.Cmp(one) == 0; k += batch {
+				ys.Set(y)
+				for i := 0; i < batch && i < r-k; i++ {
+					f(y, cc)
+					q.Mul(q, d.Sub(x, y).Abs(d)).Mod(q, n)
+				}
+				g.GCD(nil, nil, q, n)
+				limit -= batch
+			}
+		}
+
+		if g.Cmp(n) == 0 {
+			// The batch overshot; repeat it one step at a time.
+			for {
+				f(ys, cc)
+				if g.GCD(nil, nil, d.Sub(x, ys).Abs(d), n).Cmp(one) != 0 {
+					break
+				}
+			}
+		}
+		if g.Cmp(one) != 0 && g.Cmp(n) != 0 {
+			return g
+		}
+	}
+	return nil
+}
+
+// primesBelow returns the primes below n.
+func primesBelow(n uint64) []uint64 {
+	var ps []uint64
+	eachPrime(2, n, func(p uint64) bool {
+		ps = append(ps, p)
+		return true
+	})
+	return ps
+}
+
+// eachPrime calls f with the primes in [lo, hi) in increasing order until f returns
+// false.
+func eachPrime(lo, hi uint64, f func(p uint64) bool) {
+	for it := NewIterator(lo, hi); it.Next(); {
+		if !f(it.Prime()) {
+			return
+		}
+	}
+}
+
+// checkInterval is the number of primes processed between checks for a factor or
+// cancellation in the p-1 and elliptic curve methods.
+const checkInterval = 1024
+
+// pollardPM1 returns a nontrivial factor of the composite n found with Pollard's
+// p-1 method with bounds b1 and b2, or nil if it finds none or ctx is done.
+func pollardPM1(ctx context.Context, n *big.Int, b1, b2 int64) *big.Int {
+	ps := primesBelow(uint64(b1) + 1)
+	a, prev, e, g := big.NewInt(2), new(big.Int), new(big.Int), new(big.Int)
+
+	// Stage 1: a = 2^k for the product k of the prime powers up to b1. The gcd is
+	// checked regularly, so that if all factors of n appear in one chunk, the chunk
+	// can be repeated one prime at a time.
+	for i := 0; i < len(ps); i += checkInterval {
+		if ctx.Err() != nil {
+			return nil
+		}
+		chunk := ps[i:]
+		if len(chunk) > checkInterval {
+			chunk = chunk[:checkInterval]
+		}
+		prev.Set(a)
+		for _, p := range chunk {
+			a.Exp(a, e.SetUint64(primePower(p, uint64(b1))), n)
+		}
+		g.Sub(a, one).GCD(nil, nil, g, n)
+		if g.Cmp(n) == 0 {
+			a.Set(prev)
+			for _, p := range chunk {
+				a.Exp(a, e.SetUint64(primePower(p, uint64(b1))), n)
+				if g.Sub(a, one).GCD(nil, nil, g, n).Cmp(one) != 0 {
+					break
+				}
+			}
+		}
+		if g.Cmp(one) != 0 {
+			if g.Cmp(n) == 0 {
+				return nil
+			}
+			return g
+		}
+	}
+
+	// Stage 2: accumulate a^q-1 for primes q up to b2, stepping between consecutive
+	// primes with powers of a for their even gaps.
+	var (
+		x     *big.Int
+		last  uint64
+		count int
+		gaps  = make(map[uint64]*big.Int)
+		acc   = big.NewInt(1)
+		t     = new(big.Int)
+		found *big.Int
+	)
+	eachPrime(uint64(b1)+1, uint64(b2)+1, func(q uint64) bool {
+		if x == nil {
+			x = new(big.Int).Exp(a, e.SetUint64(q), n)
+		} else {
+			d, ok := gaps[q-last]
+			if !ok {
+				d = new(big.Int).Exp(a, e.SetUint64(q-last), n)
+				gaps[q-last] = d
+			}
+			x.Mul(x, d).Mod(x, n)
+		}
+		last = q
+		acc.Mul(acc, t.Sub(x, one)).Mod(acc, n)
+
+		if count++; count%checkInterval == 0 {
+			if g.GCD(nil, nil, acc, n).Cmp(one) != 0 {
+				found = g
+				return false
+			}
+			return ctx.Err() == nil
+		}
+		return true
+	})
+	if found == nil {
+		found = g.GCD(nil, nil, acc, n)
+	}
+	if found.Cmp(one) == 0 || found.Cmp(n) == 0 || ctx.Err() != nil {
+		return nil
+	}
+	return found
+}
+
+// primePower returns the largest power of p that is at most b.
+func primePower(p, b uint64) uint64 {
+	q := p
+	for q <= b/p {
+		q *= p
+	}
+	return q
+}
+
+// ecmD is half the stride of stage 2 of the elliptic curve method.
+const ecmD = 50
+
+// A point is the projective x-coordinate X:Z of a point on a Montgomery curve.
+type point struct {
+	x, z *big.Int
+}
+
+// A curve is a Montgomery curve By^2 = x^3 + Ax^2 + x modulo n, with a24 = (A+2)/4.
+type curve struct {
+	n, a24 *big.Int
+}
+
+// ecm runs one curve of Lenstra's elliptic curve method on the composite n with
+// bounds b1 and b2. It returns a nontrivial factor of n, or nil if it finds none or
+// ctx is done.
+func ecm(ctx context.Context, n *big.Int, b1, b2 int64) *big.Int {
+	if b1 < 2*ecmD+2 {
+		b1 = 2*ecmD + 2
+	}
+
+	// Suyama's parametrisation, with a random sigma in [6, n-1): u = sigma^2-5,
+	// v = 4sigma, P = u^3:v^3 and a24 = (v-u)^3(3u+v)/(16u^3v).
+	sigma, err := rand.Int(new(big.Int).Sub(n, big.NewInt(7)))
+	if err != nil {
+		return nil
+	}
+	sigma.Add(sigma, big.NewInt(6))
+	u := new(big.Int).Mul(sigma, sigma)
+	u.Sub(u, big.NewInt(5)).Mod(u, n)
+	v := new(big.Int).Lsh(sigma, 2)
+	v.Mod(v, n)
+
+	u3 := new(big.Int).Exp(u, three, n)
+	p := point{x: u3, z: new(big.Int).Exp(v, three, n)}
+
+	num := new(big.Int).Sub(v, u)
+	num.Exp(num, three, n)
+	num.Mul(num, new(big.Int).Add(new(big.Int).Mul(u, three), v)).Mod(num, n)
+	den := new(big.Int).Mul(u3, v)
+	den.Lsh(den, 4).Mod(den, n)
+	if g := new(big.Int).GCD(nil, nil, den, n); g.Cmp(one) != 0 {
+		if g.Cmp(n) == 0 {
+			return nil
+		}
+		return g
+	}
+	c := &curve{n: n, a24: num.Mul(num, den.ModInverse(den, n)).Mod(num, n)}
+
+	// Stage 1: multiply P by the prime powers up to b1.
+	g := new(big.Int)
+	for i, q := range primesBelow(uint64(b1) + 1) {
+		p = c.mul(p, primePower(q, uint64(b1)))
+		if i%checkInterval == 0 && ctx.Err() != nil {
+			return nil
+		}
+	}
+	switch g.GCD(nil, nil, p.z, n); {
+	case g.Cmp(n) == 0:
+		return nil
+	case g.Cmp(one) != 0:
+		return g
+	}
+
+	// Stage 2, as in Crandall and Pomerance's Algorithm 7.4.4: with S[d] = [2d]P,
+	// every prime q = r+2d in (r, r+2D] is tested at once with R = [r]P through
+	// X_R Z_S - X_S Z_R = (X_R-X_S)(Z_R+Z_S) - X_R Z_R + X_S Z_S.
+	s := make([]point, ecmD+1)
+	beta := make([]*big.Int, ecmD+1)
+	s[1] = c.double(p)
+	s[2] = c.double(s[1])
+	for d := 3; d <= ecmD; d++ {
+		s[d] = c.add(s[d-1], s[1], s[d-2])
+	}
+	for d := 1; d <= ecmD; d++ {
+		beta[d] = new(big.Int).Mul(s[d].x, s[d].z)
+		beta[d].Mod(beta[d], n)
+	}
+
+	r := uint64(b1) - 1 | 1
+	rp := c.mul(p, r)
+	tp := c.mul(p, r-2*ecmD)
+	alpha := new(big.Int).Mul(rp.x, rp.z)
+	alpha.Mod(alpha, n)
+
+	acc := big.NewInt(1)
+	t1, t2 := new(big.Int), new(big.Int)
+	count := 0
+	eachPrime(r+1, uint64(b2)+1, func(q uint64) bool {
+		for q > r+2*ecmD {
+			rp, tp = c.add(rp, s[ecmD], tp), rp
+			r += 2 * ecmD
+			alpha.Mul(rp.x, rp.z).Mod(alpha, n)
+		}
+		d := (q - r) / 2
+		t1.Sub(rp.x, s[d].x)
+		t2.Add(rp.z, s[d].z)
+		t1.Mul(t1, t2).Sub(t1, alpha).Add(t1, beta[d])
+		acc.Mul(acc, t1).Mod(acc, n)
+
+		if count++; count%checkInterval == 0 {
+			return ctx.Err() == nil
+		}
+		return true
+	})
+	if ctx.Err() != nil {
+		return nil
+	}
+	if g.GCD(nil, nil, acc, n); g.Cmp(one) == 0 || g.Cmp(n) == 0 {
+		return nil
+	}
+	return g
+}
+
+// double returns [2]P.
+func (c *curve) double(p point) point {
+	t1 := new(big.Int).Add(p.x, p.z)
+	t1.Mul(t1, t1).Mod(t1, c.n)
+	t2 := new(big.Int).Sub(p.x, p.z)
+	t2.Mul(t2, t2).Mod(t2, c.n)
+
+	x := new(big.Int).Mul(t1, t2)
+	x.Mod(x, c.n)
+	t3 := t1.Sub(t1, t2)
+	z := new(big.Int).Mul(c.a24, t3)
+	z.Add(z, t2).Mul(z, t3).Mod(z, c.n)
+	return point{x: x, z: z}
+}
+
+// add returns P+Q, given their difference P-Q.
+func (c *curve) add(p, q, diff point) point {
+	u := new(big.Int).Sub(p.x, p.z)
+	u.Mul(u, new(big.Int).Add(q.x, q.z))
+	v := new(big.Int).Add(p.x, p.z)
+	v.Mul(v, new(big.Int).Sub(q.x, q.z))
+
+	x := new(big.Int).Add(u, v)
+	x.Mul(x, x).Mod(x, c.n).Mul(x, diff.z).Mod(x, c.n)
+	z := u.Sub(u, v)
+	z.Mul(z, z).Mod(z, c.n).Mul(z, diff.x).Mod(z, c.n)
+	return point{x: x, z: z}
+}
+
+// mul returns [k]P for k > 0 with the Montgomery ladder.
+func (c *curve) mul(p point, k uint64) point {
+	if k == 1 {
+		return p
+	}
+	r0, r1 := p, c.double(p)
+	for i := 62 - bits.LeadingZeros64(k); i >= 0; i-- {
+		if k>>uint(i)&1 == 1 {
+			r0, r1 = c.add(r1, r0, p), c.double(r1)
+		} else {
+			r0, r1 = c.double(r0), c.add(r1, r0, p)
+		}
+	}
+	return r0
+}
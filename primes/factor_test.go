@@ -0,0 +1,197 @@
+package primes
+
+import (
+	"context"
+	"math/big"
+	"testing"
+)
+
+var (
+	rhoOnly = &FactorOptions{PM1Bound: -1, ECMCurves: -1, SieveBits: -1}
+	pm1Only = &FactorOptions{RhoIterations: -1, ECMCurves: -1, SieveBits: -1}
+	ecmOnly = &FactorOptions{RhoIterations: -1, PM1Bound: -1, ECMCurves: 1000, SieveBits: -1}
+)
+
+func TestFactorSmall(t *testing.T) {
+	fs, err := Factor(context.Background(), big.NewInt(1), nil)
+	if err != nil || len(fs) != 0 {
+		t.Fatalf("Expected an empty factorization of 1, got %v (%v)", fs, err)
+	}
+
+	for _, s := range []string{
+		"2", "1024", "720720", "4294967297", "18446744073709551615", "18446744073709551557",
+		"18446744073709551617", // 2^64+1
+	} {
+		n, _ := new(big.Int).SetString(s, 10)
+		assertFactors(t, n, nil)
+	}
+}
+
+func TestFactorRandom(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		n := big.NewInt(1)
+		for _, b := range []int{20, 32, 40} {
+			p, err := Find(b, 20)
+			if err != nil {
+				t.Fatalf("Failed to find prime: %v", err)
+			}
+			n.Mul(n, p)
+		}
+		assertFactors(t, n, rhoOnly)
+	}
+}
+
+func TestFactorPowers(t *testing.T) {
+	p, err := Find(40, 20)
+	if err != nil {
+		t.Fatalf("Failed to find prime: %v", err)
+	}
+	n := new(big.Int).Exp(p, big.NewInt(3), nil)
+	fs := assertFactors(t, n, nil)
+	if len(fs) != 1 || fs[0].K != 3 {
+		t.Fatalf("Expected %s^3, got %v", p, fs)
+	}
+
+	// Powers of small primes are found without trial division, or any method that
+	// could split them.
+	powersOnly := &FactorOptions{RhoIterations: -1, PM1Bound: -1, ECMCurves: -1, SieveBits: -1}
+	for _, c := range []struct{ p, k, bound int64 }{{3, 41, -1}, {3, 2, -1}, {101, 17, 100}, {65537, 7, 1 << 16}} {
+		o := *powersOnly
+		o.TrialBound = int(c.bound)
+		n := new(big.Int).Exp(big.NewInt(c.p), big.NewInt(c.k), nil)
+		fs := assertFactors(t, n, &o)
+		if len(fs) != 1 || fs[0].K != int(c.k) {
+			t.Fatalf("Expected %d^%d, got %v", c.p, c.k, fs)
+		}
+	}
+}
+
+func TestFactorPM1(t *testing.T) {
+	// p-1 = 2*1999993*m*k for a product m of distinct primes in [1000, 20000) and an
+	// odd k < 1000, so its prime powers are below the default stage 1 bound except
+	// for 1999993, which is below the stage 2 bound.
+	m := big.NewInt(2 * 1999993)
+	for i := 200; m.BitLen() < 80; i += 5 {
+		m.Mul(m, big.NewInt(int64(sievePrimes[i])))
+	}
+	p := new(big.Int)
+	for k := int64(1); ; k += 2 {
+		if k >= 1000 {
+			t.Fatalf("Failed to find a prime with smooth p-1")
+		}
+		if p.Mul(m, big.NewInt(k)).Add(p, one).ProbablyPrime(20) {
+			break
+		}
+	}
+	q, err := Find(256, 20)
+	if err != nil {
+		t.Fatalf("Failed to find prime: %v", err)
+	}
+	assertFactors(t, new(big.Int).Mul(p, q), pm1Only)
+}
+
+func TestFactorECM(t *testing.T) {
+	for _, b := range []int{32, 48} {
+		p, err := Find(b, 20)
+		if err != nil {
+			t.Fatalf("Failed to find prime: %v", err)
+		}
+		q, err := Find(200, 20)
+		if err != nil {
+			t.Fatalf("Failed to find prime: %v", err)
+		}
+		assertFactors(t, new(big.Int).Mul(p, q), ecmOnly)
+	}
+}
+
+func TestFactorIncomplete(t *testing.T) {
+	p, err := Find(128, 20)
+	if err != nil {
+		t.Fatalf("Failed to find prime: %v", err)
+	}
+	q, err := Find(128, 20)
+	if err != nil {
+		t.Fatalf("Failed to find prime: %v", err)
+	}
+	n := new(big.Int).Mul(p, q)
+	n.Mul(n, big.NewInt(3*3*5))
+
+	opts := &FactorOptions{RhoIterations: 1000, PM1Bound: -1, ECMCurves: -1, SieveBits: -1}
+	fs, err := Factor(context.Background(), n, opts)
+	if err != ErrIncomplete {
+		t.Fatalf("Expected ErrIncomplete, got %v", err)
+	}
+	assertProduct(t, n, fs)
+	if len(fs) != 3 {
+		t.Fatalf("Expected 3, 5 and a composite factor, got %v", fs)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	fs, err = Factor(ctx, n, nil)
+	if err != context.Canceled {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	assertProduct(t, n, fs)
+}
+
+func TestEachPrime(t *testing.T) {
+	for _, r := range [][2]uint64{{0, 10000}, {1<<32 - 100000, 1 << 32}, {65521, 65539}} {
+		var ps []uint64
+		eachPrime(r[0], r[1], func(p uint64) bool {
+			ps = append(ps, p)
+			return true
+		})
+		for v := r[0]; v < r[1]; v++ {
+			prime := new(big.Int).SetUint64(v).ProbablyPrime(0)
+			if found := len(ps) > 0 && ps[0] == v; found != prime {
+				t.Fatalf("eachPrime and ProbablyPrime disagree on %d", v)
+			}
+			if len(ps) > 0 && ps[0] == v {
+				ps = ps[1:]
+			}
+		}
+	}
+}
+
+func BenchmarkFactorECM(b *testing.B) {
+	p, _ := Find(40, 20)
+	q, _ := Find(200, 20)
+	n := new(big.Int).Mul(p, q)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Factor(context.Background(), n, ecmOnly)
+	}
+}
+
+func assertFactors(t *testing.T, n *big.Int, opts *FactorOptions) []PrimePower {
+	t.Helper()
+	fs, err := Factor(context.Background(), n, opts)
+	if err != nil {
+		t.Fatalf("Failed to factor %s: %v", n, err)
+	}
+	assertProduct(t, n, fs)
+	for i, f := range fs {
+		if !f.P.ProbablyPrime(20) {
+			t.Fatalf("Factor %s of %s is composite", f.P, n)
+		}
+		if i > 0 && fs[i-1].P.Cmp(f.P) >= 0 {
+			t.Fatalf("Factors of %s are not sorted: %v", n, fs)
+		}
+	}
+	return fs
+}
+
+func assertProduct(t *testing.T, n *big.Int, fs []PrimePower) {
+	t.Helper()
+	prod := big.NewInt(1)
+	for _, f := range fs {
+		if f.K <= 0 {
+			t.Fatalf("Factor %s of %s has exponent %d", f.P, n, f.K)
+		}
+		prod.Mul(prod, new(big.Int).Exp(f.P, big.NewInt(int64(f.K)), nil))
+	}
+	if prod.Cmp(n) != 0 {
+		t.Fatalf("Factors of %s multiply to %s", n, prod)
+	}
+}
@@ -0,0 +1,54 @@
+//go:build go1.18
+// +build go1.18
+
+package primes
+
+import (
+	"math/big"
+	"testing"
+)
+
+func FuzzJacobi(f *testing.F) {
+	f.Add([]byte{1}, false, []byte{3}, false)
+	f.Add([]byte{5}, true, []byte{21}, false)
+	f.Add([]byte{0}, false, []byte{8}, false)
+	f.Add([]byte{7}, false, []byte{9}, true)
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, false, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfb}, false)
+
+	f.Fuzz(func(t *testing.T, ab []byte, aNeg bool, bb []byte, bNeg bool) {
+		a, b := new(big.Int).SetBytes(ab), new(big.Int).SetBytes(bb)
+		if aNeg {
+			a.Neg(a)
+		}
+		if bNeg {
+			b.Neg(b)
+		}
+
+		j, err := Jacobi(a, b)
+		switch {
+		case b.Sign() <= 0 || b.Bit(0) == 0:
+			if err != ErrJacobiModulus {
+				t.Fatalf("Expected ErrJacobiModulus for J(%s, %s), got %v", a, b, err)
+			}
+		case err != nil:
+			t.Fatalf("Failed to compute J(%s, %s): %v", a, b, err)
+		case j != big.Jacobi(a, b):
+			t.Fatalf("Expected J(%s, %s) = %d, got %d", a, b, big.Jacobi(a, b), j)
+		}
+
+		// K(a, b) is the Jacobi symbol for odd b, and K(a, b) = K(a, b/2)K(a, 2) for
+		// even, non-zero b.
+		k := Kronecker(a, b)
+		switch {
+		case b.Bit(0) == 1:
+			if exp := big.Jacobi(a, b); k != exp {
+				t.Fatalf("Expected K(%s, %s) = %d, got %d", a, b, exp, k)
+			}
+		case b.Sign() != 0:
+			exp := Kronecker(a, new(big.Int).Quo(b, two)) * Kronecker(a, two)
+			if k != exp {
+				t.Fatalf("Expected K(%s, %s) = %d, got %d", a, b, exp, k)
+			}
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package primes
+
+import (
+	"math/big"
+)
+
+// BailliePSW performs the Baillie-PSW primality test on p, which combines a strong
+// probable prime test to base 2 with a strong Lucas probable prime test. The test is
+// deterministic. No composite number passing it is known, and there are none below
+// 2^64.
+func BailliePSW(p *big.Int) bool {
+	if p.Cmp(two) <= 0 || p.Bit(0) == 0 {
+		return p.Cmp(two) == 0
+	}
+
+	if hasSmallFactor(p, sievePrimes[:quickPrimes]) {
+		return false
+	}
+
+	return strongProbablePrime(p, two) && StrongLucas(p)
+}
+
+// StrongLucas performs a strong Lucas probable prime test on p, with the parameters
+// P = 1 and Q = (1-D)/4 chosen by Selfridge's method A: D is the first number in the
+// sequence 5, -7, 9, -11, ... for which the Jacobi symbol (D/p) is -1.
+func StrongLucas(p *big.Int) bool {
+	if p.Cmp(two) <= 0 || p.Bit(0) == 0 {
+		return p.Cmp(two) == 0
+	}
+
+	// (D/p) is never -1 if p is a square.
+	if r := new(big.Int).Sqrt(p); r.Mul(r, r).Cmp(p) == 0 {
+		return false
+	}
+
+	d := big.NewInt(5)
+	for {
+		j := jacobi(d, p)
+		if j == -1 {
+			break
+		}
+		if j == 0 && new(big.Int).Abs(d).Cmp(p) != 0 {
+			return false // d shares a factor with p
+		}
+
+		// 5, -7, 9, -11, ...
+		if d.Sign() > 0 {
+			d.Add(d, two).Neg(d)
+		} else {
+			d.Neg(d).Add(d, two)
+		}
+	}
+	q := new(big.Int).Sub(one, d)
+	q.Rsh(q, 2) // (1-D)/4 is exact since D = 1 (mod 4)
+
+	// p+1 = k*2^s with k odd
+	k := new(big.Int).Add(p, one)
+	s := trailingZeroes(k)
+	k.Rsh(k, s)
+
+	u, v, qk := lucas(k, d, q, p)
+	if u.Sign() == 0 || v.Sign() == 0 {
+		return true
+	}
+
+	// V(2m) = V(m)^2 - 2Q^m
+	for r := uint(1); r < s; r++ {
+		v.Mul(v, v).Sub(v, qk).Sub(v, qk).Mod(v, p)
+		if v.Sign() == 0 {
+			return true
+		}
+		qk.Mul(qk, qk).Mod(qk, p)
+	}
+	return false
+}
+
+// lucas computes U(k) and V(k) of the Lucas sequences with P = 1 and discriminant
+// d = 1-4q, and q^k, all modulo the odd number n.
+func lucas(k, d, q, n *big.Int) (u, v, qk *big.Int) {
+	d = new(big.Int).Mod(d, n)
+	q = new(big.Int).Mod(q, n)
+
+	u = big.NewInt(1)
+	v = big.NewInt(1) // P
+	qk = new(big.Int).Set(q)
+
+	t := new(big.Int)
+	for i := k.BitLen() - 2; i >= 0; i-- {
+		// U(2m) = U(m)V(m), V(2m) = V(m)^2 - 2Q^m
+		u.Mul(u, v).Mod(u, n)
+		v.Mul(v, v).Sub(v, qk).Sub(v, qk).Mod(v, n)
+		qk.Mul(qk, qk).Mod(qk, n)
+
+		if k.Bit(i) == 1 {
+			// U(m+1) = (PU(m) + V(m))/2, V(m+1) = (DU(m) + PV(m))/2
+			t.Mul(d, u).Mod(t, n)
+			u.Add(u, v)
+			v.Add(v, t)
+			halve(u, n)
+			halve(v, n)
+			qk.Mul(qk, q).Mod(qk, n)
+		}
+	}
+	return u, v, qk
+}
+
+// halve sets x to x/2 (mod n) for odd n and non-negative x < 2n.
+func halve(x, n *big.Int) {
+	if x.Bit(0) == 1 {
+		x.Add(x, n)
+	}
+	x.Rsh(x, 1).Mod(x, n)
+}
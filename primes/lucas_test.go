@@ -0,0 +1,128 @@
+package primes
+
+import (
+	crand "crypto/rand"
+	"math/big"
+	"testing"
+)
+
+// Strong pseudoprimes to base 2 below 10^5 (OEIS A001262).
+var strongPseudoprimes2 = []int64{2047, 3277, 4033, 4681, 8321, 15841, 29341, 42799, 49141, 52633, 65281, 74665, 80581, 85489, 88357, 90751}
+
+// Strong Lucas pseudoprimes with Selfridge's parameters below 10^5 (OEIS A217255).
+var strongLucasPseudoprimes = []int64{5459, 5777, 10877, 16109, 18971, 22499, 24569, 25199, 40309, 58519, 75077, 97439}
+
+func TestStrongPseudoprimes(t *testing.T) {
+	var spsp, slpsp []int64
+	for i := int64(3); i < 100000; i += 2 {
+		p := big.NewInt(i)
+		if p.ProbablyPrime(0) {
+			continue
+		}
+		if strongProbablePrime(p, two) {
+			spsp = append(spsp, i)
+		}
+		if StrongLucas(p) {
+			slpsp = append(slpsp, i)
+		}
+	}
+	assertList(t, "strong pseudoprimes to base 2", spsp, strongPseudoprimes2)
+	assertList(t, "strong Lucas pseudoprimes", slpsp, strongLucasPseudoprimes)
+}
+
+func TestBailliePSWPseudoprimes(t *testing.T) {
+	var ps []*big.Int
+	for _, i := range append(strongPseudoprimes2, strongLucasPseudoprimes...) {
+		ps = append(ps, big.NewInt(i))
+	}
+	// Strong pseudoprimes to base 2 near 2^64, and to every prime base up to 23.
+	for _, s := range []string{"18446744066047760377", "3825123056546413051"} {
+		p, _ := new(big.Int).SetString(s, 10)
+		if !strongProbablePrime(p, two) {
+			t.Fatalf("%s is not a strong pseudoprime to base 2", s)
+		}
+		ps = append(ps, p)
+	}
+
+	for _, p := range ps {
+		if BailliePSW(p) {
+			t.Fatalf("Pseudoprime %s reported as prime", p)
+		}
+	}
+}
+
+func TestBailliePSWSmall(t *testing.T) {
+	for i := int64(0); i < 100000; i++ {
+		assertDeterministic(t, BailliePSW, big.NewInt(i))
+	}
+}
+
+func TestBailliePSW(t *testing.T) {
+	for _, bits := range []uint{32, 64, 128, 512, 1024} {
+		max := new(big.Int).Lsh(one, bits)
+		for i := 0; i < iters; i++ {
+			j, err := crand.Int(crand.Reader, max)
+			if err != nil {
+				t.Fatalf("Failed to generate random prime candidate: %v", err)
+			}
+			assertDeterministic(t, BailliePSW, j)
+		}
+
+		// Random primes are rare, so test some on purpose.
+		p, err := crand.Prime(crand.Reader, int(bits))
+		if err != nil {
+			t.Fatalf("Failed to generate random prime: %v", err)
+		}
+		if !BailliePSW(p) || !StrongLucas(p) {
+			t.Fatalf("Prime %s reported as composite", p)
+		}
+	}
+}
+
+func BenchmarkBailliePSW512(b *testing.B) {
+	benchmarkPrime(512, func(p *big.Int) { BailliePSW(p) }, b)
+}
+
+func BenchmarkBailliePSW2048(b *testing.B) {
+	benchmarkPrime(2048, func(p *big.Int) { BailliePSW(p) }, b)
+}
+
+func BenchmarkMillerRabin512(b *testing.B) {
+	benchmarkPrime(512, func(p *big.Int) { MillerRabin(p, 40) }, b)
+}
+
+func BenchmarkMillerRabin2048(b *testing.B) {
+	benchmarkPrime(2048, func(p *big.Int) { MillerRabin(p, 40) }, b)
+}
+
+func benchmarkPrime(bits int, f func(*big.Int), b *testing.B) {
+	p, err := crand.Prime(crand.Reader, bits)
+	if err != nil {
+		b.Fatalf("Failed to generate random prime: %v", err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f(p)
+	}
+}
+
+// assertDeterministic checks the primality test f against the Baillie-PSW test of
+// the standard library, which is exact below 2^64.
+func assertDeterministic(t *testing.T, f func(*big.Int) bool, p *big.Int) {
+	t.Helper()
+	if actual, exp := f(p), p.ProbablyPrime(0); actual != exp {
+		t.Fatalf("Expected primality of %s to be %t, got %t", p, exp, actual)
+	}
+}
+
+func assertList(t *testing.T, name string, actual, exp []int64) {
+	t.Helper()
+	if len(actual) != len(exp) {
+		t.Fatalf("Expected %s %v, got %v", name, exp, actual)
+	}
+	for i := range exp {
+		if actual[i] != exp[i] {
+			t.Fatalf("Expected %s %v, got %v", name, exp, actual)
+		}
+	}
+}
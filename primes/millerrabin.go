@@ -0,0 +1,87 @@
+package primes
+
+import (
+	"math/big"
+
+	"github.com/mmussomele/crypto/rand"
+)
+
+// Every composite number below deterministicLimit is a strong pseudoprime to at
+// least one of deterministicBases (Sorenson and Webster, 2015).
+var (
+	deterministicLimit, _ = new(big.Int).SetString("3317044064679887385961981", 10)
+	deterministicBases    = []int64{2, 3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41}
+)
+
+// MillerRabin performs a Miller-Rabin primality test on p. The probability of a false
+// positive is at most 2^(-n).
+//
+// Each round has an error probability of at most 4^(-1), so MillerRabin performs half
+// as many rounds as Is for the same n. Numbers below 3.3*10^24 are tested against a
+// fixed set of bases, which is deterministic.
+func MillerRabin(p *big.Int, n int) (bool, error) {
+	return millerRabin(p, (n+1)/2)
+}
+
+// millerRabin performs a Miller-Rabin primality test on p with the given number of
+// random bases, or with the deterministic bases if p is small enough.
+func millerRabin(p *big.Int, rounds int) (bool, error) {
+	if p.Cmp(two) <= 0 || p.Bit(0) == 0 {
+		return p.Cmp(two) == 0, nil
+	}
+	if hasSmallFactor(p, sievePrimes[:quickPrimes]) {
+		return false, nil
+	}
+
+	if p.Cmp(deterministicLimit) < 0 {
+		a := new(big.Int)
+		for _, b := range deterministicBases {
+			a.SetInt64(b)
+			if a.Cmp(p) >= 0 {
+				return true, nil // p is one of the bases
+			}
+			if !strongProbablePrime(p, a) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+
+	// a is random in [2, p-1)
+	limit := new(big.Int).Sub(p, three)
+	a := new(big.Int)
+	for i := 0; i < rounds; i++ {
+		if _, err := rand.IntInto(a, limit); err != nil {
+			return false, err
+		}
+		a.Add(a, two)
+
+		if !strongProbablePrime(p, a) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// strongProbablePrime reports whether the odd number p > 2 is a strong probable prime
+// to base a.
+func strongProbablePrime(p, a *big.Int) bool {
+	p1 := new(big.Int).Sub(p, one)
+	s := trailingZeroes(p1)
+	d := new(big.Int).Rsh(p1, s)
+
+	x := new(big.Int).Exp(a, d, p)
+	if x.Cmp(one) == 0 || x.Cmp(p1) == 0 {
+		return true
+	}
+	for i := uint(1); i < s; i++ {
+		x.Mul(x, x).Mod(x, p)
+		switch {
+		case x.Cmp(p1) == 0:
+			return true
+		case x.Cmp(one) == 0:
+			return false
+		}
+	}
+	return false
+}
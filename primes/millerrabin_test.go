@@ -0,0 +1,104 @@
+package primes
+
+import (
+	crand "crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestMillerRabinSmall(t *testing.T) {
+	for i := int64(0); i < 100000; i++ {
+		assertTest(t, MillerRabin, big.NewInt(i))
+	}
+}
+
+func TestMillerRabin(t *testing.T) {
+	for _, bits := range []int{64, 82, 83, 512} {
+		max := new(big.Int).Lsh(one, uint(bits))
+		for i := 0; i < iters; i++ {
+			j, err := crand.Int(crand.Reader, max)
+			if err != nil {
+				t.Fatalf("Failed to generate random prime candidate: %v", err)
+			}
+			assertTest(t, MillerRabin, j)
+		}
+	}
+}
+
+func TestMillerRabinPseudoprimes(t *testing.T) {
+	// Strong pseudoprimes to every prime base up to 23, 37 and 41 respectively. The
+	// last one must be rejected with random bases.
+	for _, s := range []string{
+		"3825123056546413051",
+		"318665857834031151167461",
+		"3317044064679887385961981",
+	} {
+		p, _ := new(big.Int).SetString(s, 10)
+		ok, err := MillerRabin(p, 64)
+		switch {
+		case err != nil:
+			t.Fatalf("Failed to check primality: %v", err)
+		case ok:
+			t.Fatalf("Strong pseudoprime %s reported as prime", s)
+		}
+	}
+}
+
+func TestFindMillerRabin(t *testing.T) {
+	const bits = 2048
+	p, err := FindWith(MillerRabin, bits, 64)
+	if err != nil {
+		t.Fatalf("Failed to generate random prime candidate: %v", err)
+	}
+
+	gotBits := p.BitLen()
+	if gotBits < bits {
+		t.Fatalf("Expected at least %d bits, got %d", bits, gotBits)
+	}
+
+	if !p.ProbablyPrime(32) {
+		t.Fatal("Generated composite number")
+	}
+}
+
+func BenchmarkFindMillerRabin16(b *testing.B) {
+	benchmarkFindWith(MillerRabin, 16, b)
+}
+
+func BenchmarkFindMillerRabin32(b *testing.B) {
+	benchmarkFindWith(MillerRabin, 32, b)
+}
+
+func BenchmarkFindMillerRabin64(b *testing.B) {
+	benchmarkFindWith(MillerRabin, 64, b)
+}
+
+func BenchmarkFindMillerRabin128(b *testing.B) {
+	benchmarkFindWith(MillerRabin, 128, b)
+}
+
+func BenchmarkFindMillerRabin512(b *testing.B) {
+	benchmarkFindWith(MillerRabin, 512, b)
+}
+
+func BenchmarkFindMillerRabin2048(b *testing.B) {
+	benchmarkFindWith(MillerRabin, 2048, b)
+}
+
+func benchmarkFindWith(t Test, bits int, b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		FindWith(t, bits, 40) // Stdlib uses 4^(-20), we use 2^(-40)
+	}
+}
+
+// assertTest checks the primality test t against the standard library.
+func assertTest(t *testing.T, test Test, p *big.Int) {
+	t.Helper()
+	actual, err := test(p, 64)
+	if err != nil {
+		t.Fatalf("Failed to check primality: %v", err)
+	}
+	if exp := p.ProbablyPrime(32); actual != exp {
+		t.Fatalf("Expected primality of %s to be %t, got %t", p, exp, actual)
+	}
+}
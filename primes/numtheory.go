@@ -0,0 +1,327 @@
+package primes
+
+import (
+	"context"
+	"errors"
+	"math/big"
+)
+
+// Errors returned by the number theory functions.
+var (
+	ErrNotSquare       = errors.New("crypto/primes: not a quadratic residue")
+	ErrNoSolution      = errors.New("crypto/primes: congruences have no solution")
+	ErrNoPrimitiveRoot = errors.New("crypto/primes: no primitive root")
+)
+
+// TonelliShanks returns a square root of a modulo the odd prime p with the
+// Tonelli-Shanks algorithm. It returns ErrNotSquare if a is not a square modulo p.
+func TonelliShanks(a, p *big.Int) (*big.Int, error) {
+	a, err := residue(a, p)
+	if err != nil || a.Sign() == 0 {
+		return a, err
+	}
+
+	// p-1 = q*2^e with q odd, and z is a non-residue.
+	q := new(big.Int).Sub(p, one)
+	e := q.TrailingZeroBits()
+	q.Rsh(q, e)
+	z, limit := big.NewInt(2), nonResidueBound(p)
+	for jacobi(z, p) != -1 {
+		if z.Add(z, one).Cmp(limit) > 0 {
+			return nil, ErrNotSquare // only possible if p is not prime
+		}
+	}
+
+	c := new(big.Int).Exp(z, q, p)
+	t := new(big.Int).Exp(a, q, p)
+	r := new(big.Int).Add(q, one)
+	r.Rsh(r, 1).Exp(a, r, p)
+	t2, b := new(big.Int), new(big.Int)
+	for m := e; t.Cmp(one) != 0; {
+		// Find the least i with t^(2^i) = 1.
+		i := uint(0)
+		for t2.Set(t); t2.Cmp(one) != 0; i++ {
+			if i+1 >= m {
+				return nil, ErrNotSquare // only possible if p is not prime
+			}
+			t2.Mul(t2, t2).Mod(t2, p)
+		}
+		b.Set(c)
+		for j := uint(0); j < m-i-1; j++ {
+			b.Mul(b, b).Mod(b, p)
+		}
+		r.Mul(r, b).Mod(r, p)
+		c.Mul(b, b).Mod(c, p)
+		t.Mul(t, c).Mod(t, p)
+		m = i
+	}
+	return checkSqrt(r, a, p)
+}
+
+// Cipolla returns a square root of a modulo the odd prime p with Cipolla's
+// algorithm. It returns ErrNotSquare if a is not a square modulo p.
+func Cipolla(a, p *big.Int) (*big.Int, error) {
+	a, err := residue(a, p)
+	if err != nil || a.Sign() == 0 {
+		return a, err
+	}
+
+	// Find t such that w = t^2-a is not a square, and compute (t+sqrt(w))^((p+1)/2)
+	// in GF(p^2), which lies in GF(p) and is a square root of a.
+	t, w, limit := big.NewInt(1), new(big.Int), nonResidueBound(p)
+	for {
+		w.Mul(t, t).Sub(w, a).Mod(w, p)
+		if jacobi(w, p) == -1 {
+			break
+		}
+		if t.Add(t, one).Cmp(limit) > 0 {
+			return nil, ErrNotSquare // only possible if p is not prime
+		}
+	}
+
+	x0, x1 := big.NewInt(1), big.NewInt(0) // result
+	y0, y1 := t, big.NewInt(1)             // t+sqrt(w)
+	t0, t1 := new(big.Int), new(big.Int)
+	mul := func(a0, a1, b0, b1 *big.Int) {
+		// (a0 + a1 sqrt(w))(b0 + b1 sqrt(w))
+		t0.Mul(a1, b1).Mul(t0, w).Add(t0, new(big.Int).Mul(a0, b0)).Mod(t0, p)
+		t1.Mul(a0, b1).Add(t1, new(big.Int).Mul(a1, b0)).Mod(t1, p)
+		a0.Set(t0)
+		a1.Set(t1)
+	}
+	e := new(big.Int).Add(p, one)
+	e.Rsh(e, 1)
+	for i := e.BitLen() - 1; i >= 0; i-- {
+		mul(x0, x1, x0, x1)
+		if e.Bit(i) == 1 {
+			mul(x0, x1, y0, y1)
+		}
+	}
+	return checkSqrt(x0, a, p)
+}
+
+// nonResidueBound returns the largest candidate tried by the searches for a
+// non-residue modulo p. For prime p, the least non-residue is below 2*log(p)^2
+// assuming the generalized Riemann hypothesis, while modulo an odd square no number
+// has Jacobi symbol -1.
+func nonResidueBound(p *big.Int) *big.Int {
+	l := int64(p.BitLen())
+	b := big.NewInt(2*l*l + 2)
+	if b.Cmp(p) > 0 {
+		b.Set(p)
+	}
+	return b
+}
+
+// residue returns a mod p. It returns ErrNotSquare if a is not a square modulo p by
+// Euler's criterion.
+func residue(a, p *big.Int) (*big.Int, error) {
+	if p.Sign() <= 0 || p.Bit(0) == 0 || p.Cmp(one) == 0 {
+		panic("crypto/primes: modulus must be an odd prime")
+	}
+	a = new(big.Int).Mod(a, p)
+	if jacobi(a, p) == -1 {
+		return nil, ErrNotSquare
+	}
+	return a, nil
+}
+
+// checkSqrt returns r if it is a square root of a modulo m, which fails only if m is
+// not prime.
+func checkSqrt(r, a, m *big.Int) (*big.Int, error) {
+	t := new(big.Int).Mul(r, r)
+	if t.Sub(t, a).Mod(t, m).Sign() != 0 {
+		return nil, ErrNotSquare
+	}
+	return r, nil
+}
+
+// SqrtModPrimePower returns a square root of a modulo p^k for a prime p and k > 0,
+// lifting a root modulo p with Hensel's lemma. It returns ErrNotSquare if a is not a
+// square modulo p^k.
+func SqrtModPrimePower(a, p *big.Int, k int) (*big.Int, error) {
+	if k <= 0 {
+		panic("crypto/primes: prime power exponent must be positive")
+	}
+	pk := new(big.Int).Exp(p, big.NewInt(int64(k)), nil)
+	a = new(big.Int).Mod(a, pk)
+	if a.Sign() == 0 {
+		return a, nil
+	}
+
+	// a = p^v*u with u coprime to p. Then v must be even, and p^(v/2) times a root of
+	// u modulo p^(k-v) is a root of a.
+	v := 0
+	u, m := new(big.Int).Set(a), new(big.Int)
+	for m.Mod(u, p).Sign() == 0 {
+		u.Div(u, p)
+		v++
+	}
+	if v%2 == 1 {
+		return nil, ErrNotSquare
+	}
+	r, err := sqrtUnitModPrimePower(u, p, k-v)
+	if err != nil {
+		return nil, err
+	}
+	r.Mul(r, m.Exp(p, big.NewInt(int64(v/2)), nil))
+	return r.Mod(r, pk), nil
+}
+
+// sqrtUnitModPrimePower returns a square root of u, coprime to the prime p, modulo
+// p^k.
+func sqrtUnitModPrimePower(u, p *big.Int, k int) (*big.Int, error) {
+	if p.Cmp(two) == 0 {
+		// Odd squares modulo 2^k are 1 mod 2^min(k,3), and a root modulo 2^(i+1) is
+		// found by correcting a root modulo 2^i by 2^(i-1).
+		mk := new(big.Int).Lsh(one, uint(k))
+		u = new(big.Int).Mod(u, mk)
+		low := k
+		if low > 3 {
+			low = 3
+		}
+		if low >= 2 && u.Bit(1) != 0 || low == 3 && u.Bit(2) != 0 {
+			return nil, ErrNotSquare
+		}
+		r, t := big.NewInt(1), new(big.Int)
+		for i := 3; i < k; i++ {
+			m := new(big.Int).Lsh(one, uint(i+1))
+			if t.Mul(r, r).Sub(t, u).Mod(t, m).Sign() != 0 {
+				r.Add(r, new(big.Int).Lsh(one, uint(i-1)))
+			}
+		}
+		return r, nil
+	}
+
+	r, err := TonelliShanks(u, p)
+	if err != nil {
+		return nil, err
+	}
+
+	// r' = r - (r^2-u)/(2r) modulo p^2i doubles the precision of a root modulo p^i.
+	mod := new(big.Int).Set(p)
+	pk := new(big.Int).Exp(p, big.NewInt(int64(k)), nil)
+	t, inv := new(big.Int), new(big.Int)
+	for mod.Cmp(pk) < 0 {
+		mod.Mul(mod, mod)
+		if mod.Cmp(pk) > 0 {
+			mod.Set(pk)
+		}
+		t.Mul(r, r).Sub(t, u)
+		inv.Lsh(r, 1).ModInverse(inv, mod)
+		t.Mul(t, inv)
+		r.Sub(r, t).Mod(r, mod)
+	}
+	return r, nil
+}
+
+// CRT returns the solution x in [0, m) of x = residues[i] (mod moduli[i]) for every
+// i, where m is the least common multiple of the moduli, which must be positive. The
+// moduli need not be coprime. It returns ErrNoSolution if the congruences are
+// inconsistent.
+func CRT(residues, moduli []*big.Int) (x, m *big.Int, err error) {
+	if len(residues) != len(moduli) {
+		panic("crypto/primes: residues and moduli must have the same length")
+	}
+	x, m = big.NewInt(0), big.NewInt(1)
+	g, d, t, inv := new(big.Int), new(big.Int), new(big.Int), new(big.Int)
+	for i, mi := range moduli {
+		if mi.Sign() <= 0 {
+			panic("crypto/primes: moduli must be positive")
+		}
+
+		// x + m*t = residues[i] (mod mi) has a solution iff g = gcd(m, mi) divides
+		// the difference, and then t = d/g * (m/g)^-1 (mod mi/g).
+		g.GCD(nil, nil, m, mi)
+		d.Sub(residues[i], x)
+		if t.Mod(d, g).Sign() != 0 {
+			return nil, nil, ErrNoSolution
+		}
+		mg := new(big.Int).Div(mi, g)
+		d.Div(d, g)
+		inv.Div(m, g)
+		if mg.Cmp(one) == 0 {
+			continue
+		}
+		inv.ModInverse(inv.Mod(inv, mg), mg)
+		t.Mul(d, inv).Mod(t, mg)
+
+		x.Add(x, t.Mul(t, m))
+		m.Mul(m, mg)
+		x.Mod(x, m)
+	}
+	return x, m, nil
+}
+
+// Totient returns Euler's totient of the number with the factorization fs.
+func Totient(fs []PrimePower) *big.Int {
+	phi := big.NewInt(1)
+	t := new(big.Int)
+	for _, f := range fs {
+		phi.Mul(phi, t.Sub(f.P, one))
+		phi.Mul(phi, t.Exp(f.P, big.NewInt(int64(f.K-1)), nil))
+	}
+	return phi
+}
+
+// Carmichael returns Carmichael's function, the exponent of the multiplicative group,
+// of the number with the factorization fs.
+func Carmichael(fs []PrimePower) *big.Int {
+	lambda := big.NewInt(1)
+	g := new(big.Int)
+	for _, f := range fs {
+		l := Totient([]PrimePower{f})
+		if f.P.Cmp(two) == 0 && f.K >= 3 {
+			l.Rsh(l, 1)
+		}
+		g.GCD(nil, nil, lambda, l)
+		lambda.Mul(lambda, l.Div(l, g))
+	}
+	return lambda
+}
+
+// PrimitiveRoot returns the least primitive root modulo n > 1, factoring n and its
+// totient with Factor. It returns ErrNoPrimitiveRoot if n is not 2, 4, p^k or 2p^k
+// for an odd prime p, and the error from Factor if it can not factor them.
+func PrimitiveRoot(ctx context.Context, n *big.Int) (*big.Int, error) {
+	if n.Cmp(one) <= 0 {
+		panic("crypto/primes: primitive roots require a modulus greater than 1")
+	}
+	fs, err := Factor(ctx, n, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case n.Cmp(big.NewInt(4)) <= 0:
+	case len(fs) == 1 && fs[0].P.Cmp(two) != 0:
+	case len(fs) == 2 && fs[0].P.Cmp(two) == 0 && fs[0].K == 1:
+	default:
+		return nil, ErrNoPrimitiveRoot
+	}
+
+	phi := Totient(fs)
+	qs, err := Factor(ctx, phi, nil)
+	if err != nil {
+		return nil, err
+	}
+	g, e, t := big.NewInt(1), new(big.Int), new(big.Int)
+	for g.Add(g, one); g.Cmp(n) < 0; g.Add(g, one) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if t.GCD(nil, nil, g, n).Cmp(one) != 0 {
+			continue
+		}
+		ok := true
+		for _, q := range qs {
+			if t.Exp(g, e.Div(phi, q.P), n).Cmp(one) == 0 {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return g, nil
+		}
+	}
+	return big.NewInt(1), nil // n = 2
+}
@@ -0,0 +1,245 @@
+package primes
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func TestModSqrt(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	ps := []*big.Int{big.NewInt(3), big.NewInt(5), big.NewInt(17), big.NewInt(65537)}
+	for _, b := range []int{32, 64, 256} {
+		p, err := Find(b, 20)
+		if err != nil {
+			t.Fatalf("Failed to find prime: %v", err)
+		}
+		ps = append(ps, p)
+	}
+	// p-1 divisible by a large power of 2 exercises the Tonelli-Shanks loop.
+	p, _ := new(big.Int).SetString("3618502788666131213697322783095070105623107215331596699973092056135872020481", 10)
+	ps = append(ps, p)
+
+	for _, p := range ps {
+		for i := 0; i < 50; i++ {
+			a := new(big.Int).Rand(rng, p)
+			want := new(big.Int).ModSqrt(a, p)
+			for _, sqrt := range []func(a, p *big.Int) (*big.Int, error){TonelliShanks, Cipolla} {
+				r, err := sqrt(a, p)
+				if want == nil {
+					if err != ErrNotSquare {
+						t.Fatalf("Expected ErrNotSquare for %s mod %s, got %v", a, p, err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("Failed to find square root of %s mod %s: %v", a, p, err)
+				}
+				assertSqrt(t, r, a, p)
+			}
+		}
+	}
+}
+
+func TestModSqrtSquareModulus(t *testing.T) {
+	// No residue modulo an odd square has Jacobi symbol -1, so the searches for a
+	// non-residue must give up. Any root returned must still be correct.
+	for _, p := range []int64{9, 25, 225} {
+		bp := big.NewInt(p)
+		for a := int64(0); a < p; a++ {
+			for _, sqrt := range []func(a, p *big.Int) (*big.Int, error){TonelliShanks, Cipolla} {
+				r, err := sqrt(big.NewInt(a), bp)
+				switch {
+				case err == nil:
+					assertSqrt(t, r, big.NewInt(a), bp)
+				case err != ErrNotSquare:
+					t.Fatalf("Expected ErrNotSquare for %d mod %d, got %v", a, p, err)
+				}
+			}
+		}
+	}
+	for _, sqrt := range []func(a, p *big.Int) (*big.Int, error){TonelliShanks, Cipolla} {
+		if _, err := sqrt(big.NewInt(2), big.NewInt(9)); err != ErrNotSquare {
+			t.Fatalf("Expected ErrNotSquare for 2 mod 9, got %v", err)
+		}
+	}
+}
+
+func TestSqrtModPrimePower(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for _, p := range []int64{2, 3, 5, 65537} {
+		for _, k := range []int{1, 2, 3, 4, 7, 20} {
+			pk := new(big.Int).Exp(big.NewInt(p), big.NewInt(int64(k)), nil)
+			for i := 0; i < 50; i++ {
+				x := new(big.Int).Rand(rng, pk)
+				if i%5 == 0 {
+					x.Mul(x, big.NewInt(p))
+				}
+				a := new(big.Int).Mul(x, x)
+				a.Mod(a, pk)
+				r, err := SqrtModPrimePower(a, big.NewInt(p), k)
+				if err != nil {
+					t.Fatalf("Failed to find square root of %s mod %d^%d: %v", a, p, k, err)
+				}
+				assertSqrt(t, r, a, pk)
+			}
+		}
+	}
+
+	for _, c := range []struct {
+		a, p int64
+		k    int
+	}{{3, 2, 2}, {5, 2, 3}, {2, 3, 2}, {3, 3, 2}, {3, 3, 3}, {5, 5, 3}} {
+		if _, err := SqrtModPrimePower(big.NewInt(c.a), big.NewInt(c.p), c.k); err != ErrNotSquare {
+			t.Fatalf("Expected ErrNotSquare for %d mod %d^%d, got %v", c.a, c.p, c.k, err)
+		}
+	}
+}
+
+func TestCRT(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	for i := 0; i < 200; i++ {
+		n := 1 + rng.Intn(5)
+		x := big.NewInt(rng.Int63())
+		rs, ms := make([]*big.Int, n), make([]*big.Int, n)
+		lcm := big.NewInt(1)
+		g := new(big.Int)
+		for j := range ms {
+			ms[j] = big.NewInt(1 + rng.Int63n(1000))
+			rs[j] = new(big.Int).Mod(x, ms[j])
+			if j%2 == 1 {
+				rs[j].Add(rs[j], new(big.Int).Mul(ms[j], big.NewInt(rng.Int63n(10)-5)))
+			}
+			g.GCD(nil, nil, lcm, ms[j])
+			lcm.Mul(lcm, ms[j]).Div(lcm, g)
+		}
+
+		y, m, err := CRT(rs, ms)
+		if err != nil {
+			t.Fatalf("Failed to solve %v mod %v: %v", rs, ms, err)
+		}
+		if m.Cmp(lcm) != 0 {
+			t.Fatalf("Expected modulus %s, got %s", lcm, m)
+		}
+		if y.Sign() < 0 || y.Cmp(m) >= 0 || y.Cmp(new(big.Int).Mod(x, m)) != 0 {
+			t.Fatalf("Expected %s mod %s, got %s", x, m, y)
+		}
+	}
+
+	rs := []*big.Int{big.NewInt(1), big.NewInt(2)}
+	ms := []*big.Int{big.NewInt(4), big.NewInt(6)}
+	if _, _, err := CRT(rs, ms); err != ErrNoSolution {
+		t.Fatalf("Expected ErrNoSolution, got %v", err)
+	}
+	x, m, err := CRT(nil, nil)
+	if err != nil || x.Sign() != 0 || m.Cmp(one) != 0 {
+		t.Fatalf("Expected 0 mod 1 for no congruences, got %s mod %s (%v)", x, m, err)
+	}
+}
+
+func TestTotientCarmichael(t *testing.T) {
+	for n := int64(1); n < 600; n++ {
+		fs, err := Factor(context.Background(), big.NewInt(n), nil)
+		if err != nil {
+			t.Fatalf("Failed to factor %d: %v", n, err)
+		}
+
+		// Brute force the totient and the exponent of the group of units.
+		phi, lambda := int64(0), int64(1)
+		for a := int64(1); a <= n; a++ {
+			if gcd(a, n) != 1 {
+				continue
+			}
+			phi++
+			k := int64(1)
+			for x := a % n; x != 1%n; x = x * a % n {
+				k++
+			}
+			lambda = lambda / gcd(lambda, k) * k
+		}
+		if got := Totient(fs); got.Int64() != phi {
+			t.Fatalf("Expected totient %d of %d, got %s", phi, n, got)
+		}
+		if got := Carmichael(fs); got.Int64() != lambda {
+			t.Fatalf("Expected Carmichael function %d of %d, got %s", lambda, n, got)
+		}
+	}
+}
+
+func TestPrimitiveRoot(t *testing.T) {
+	ctx := context.Background()
+	for n := int64(2); n < 500; n++ {
+		fs, err := Factor(ctx, big.NewInt(n), nil)
+		if err != nil {
+			t.Fatalf("Failed to factor %d: %v", n, err)
+		}
+		phi := Totient(fs).Int64()
+
+		// The least g whose multiplicative order is phi(n).
+		want := int64(0)
+		for g := int64(1); g < n && want == 0; g++ {
+			if gcd(g, n) != 1 {
+				continue
+			}
+			k := int64(1)
+			for x := g % n; x != 1%n; x = x * g % n {
+				k++
+			}
+			if k == phi {
+				want = g
+			}
+		}
+
+		g, err := PrimitiveRoot(ctx, big.NewInt(n))
+		if want == 0 {
+			if err != ErrNoPrimitiveRoot {
+				t.Fatalf("Expected ErrNoPrimitiveRoot for %d, got %v", n, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Failed to find primitive root of %d: %v", n, err)
+		}
+		if g.Int64() != want {
+			t.Fatalf("Expected primitive root %d of %d, got %s", want, n, g)
+		}
+	}
+
+	// 2^127-1 has a large totient to factor.
+	p := new(big.Int).Sub(new(big.Int).Lsh(one, 127), one)
+	g, err := PrimitiveRoot(ctx, p)
+	if err != nil {
+		t.Fatalf("Failed to find primitive root of %s: %v", p, err)
+	}
+	if g.Cmp(big.NewInt(43)) != 0 {
+		t.Fatalf("Expected primitive root 43 of %s, got %s", p, g)
+	}
+}
+
+func BenchmarkTonelliShanks(b *testing.B) {
+	p, _ := new(big.Int).SetString("3618502788666131213697322783095070105623107215331596699973092056135872020481", 10)
+	a := big.NewInt(5)
+	a.Mul(a, a)
+	for i := 0; i < b.N; i++ {
+		TonelliShanks(a, p)
+	}
+}
+
+func assertSqrt(t *testing.T, r, a, m *big.Int) {
+	t.Helper()
+	if r.Sign() < 0 || r.Cmp(m) >= 0 {
+		t.Fatalf("Square root %s of %s is not reduced mod %s", r, a, m)
+	}
+	x := new(big.Int).Mul(r, r)
+	if x.Sub(x, a).Mod(x, m).Sign() != 0 {
+		t.Fatalf("%s is not a square root of %s mod %s", r, a, m)
+	}
+}
+
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
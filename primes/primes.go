@@ -1,21 +1,41 @@
 package primes
 
 import (
+	"context"
+	"errors"
 	"math/big"
 	"math/bits"
 
 	"github.com/mmussomele/crypto/rand"
 )
 
+// ErrNoPrime is returned when there is no prime satisfying the requested
+// constraints.
+var ErrNoPrime = errors.New("crypto/primes: no prime found")
+
+// ErrJacobiModulus is returned when the modulus of a Jacobi symbol is not odd and
+// positive.
+var ErrJacobiModulus = errors.New("crypto/primes: Jacobi symbol modulus must be odd and positive")
+
 var (
-	zero = big.NewInt(0)
-	one  = big.NewInt(1)
-	two  = big.NewInt(2)
+	zero  = big.NewInt(0)
+	one   = big.NewInt(1)
+	two   = big.NewInt(2)
+	three = big.NewInt(3)
 )
 
+// A Test is a probabilistic primality test. The probability that it reports a
+// composite p as prime is at most 2^(-n).
+type Test func(p *big.Int, n int) (bool, error)
+
 // Find finds a random prime number of at least b bits. The probability that the
 // returned number is not prime is at most 2^(-n).
 func Find(b, n int) (*big.Int, error) {
+	return FindWith(Is, b, n)
+}
+
+// FindWith is like Find, but uses the primality test t.
+func FindWith(t Test, b, n int) (*big.Int, error) {
 	p := new(big.Int)
 	buf := make([]byte, (b+7)/8)
 	_, err := rand.Read(buf)
@@ -26,45 +46,87 @@ func Find(b, n int) (*big.Int, error) {
 	if p.BitLen() < b {
 		p.SetBit(p, b-1, 1) // Ensure p is at least b bits
 	}
-	return FindNext(p, n)
+	return FindNextWith(t, p, n)
 }
 
 // FindNext finds the first prime number bigger than or equal to n. The probability that
 // the returned number is not prime is at most 2^(-n).
 func FindNext(s *big.Int, n int) (*big.Int, error) {
-	s = new(big.Int).SetBit(s, 0, 1)
-	for {
-		switch ok, err := Is(s, n); {
-		case err != nil:
-			return nil, err
-		case ok:
-			return s, nil
-		}
-		s.Add(s, two)
+	return FindNextWith(Is, s, n)
+}
+
+// FindNextWith is like FindNext, but uses the primality test t.
+func FindNextWith(t Test, s *big.Int, n int) (*big.Int, error) {
+	if s.Cmp(two) <= 0 {
+		return big.NewInt(2), nil
 	}
+	return search(t, new(big.Int).SetBit(s, 0, 1), 2, n)
 }
 
 // FindPrevious finds the first prime number smaller than or equal to n. The probability
-// that the returned number is not prime is at most 2^(-n).
+// that the returned number is not prime is at most 2^(-n). It returns ErrNoPrime if
+// s is smaller than 2.
 func FindPrevious(s *big.Int, n int) (*big.Int, error) {
+	return FindPreviousWith(Is, s, n)
+}
+
+// FindPreviousWith is like FindPrevious, but uses the primality test t.
+func FindPreviousWith(t Test, s *big.Int, n int) (*big.Int, error) {
+	switch s.Cmp(two) {
+	case -1:
+		return nil, ErrNoPrime
+	case 0:
+		return big.NewInt(2), nil
+	}
+
 	s = new(big.Int).Set(s)
 	if s.Bit(0) == 0 {
 		s.Sub(s, one)
 	}
+	return search(t, s, -2, n)
+}
+
+// search returns the first prime in s, s+step, s+2*step, ... for odd s and step 2 or
+// -2. Candidates with small factors are skipped without running t.
+func search(t Test, s *big.Int, step int64, n int) (*big.Int, error) {
+	sv := newSieve(s, step)
 	for {
-		switch ok, err := Is(s, n); {
-		case err != nil:
-			return nil, err
-		case ok:
-			return s, nil
+		for i, comp := range sv.comp {
+			if comp {
+				continue
+			}
+
+			c := sv.candidate(new(big.Int), i)
+			if c.Cmp(three) < 0 {
+				return big.NewInt(2), nil // searching down reached 2
+			}
+			switch ok, err := t(c, n); {
+			case err != nil:
+				return nil, err
+			case ok:
+				return c, nil
+			}
 		}
-		s.Sub(s, two)
+		sv.advance()
 	}
 }
 
 // Is performs a Solovay-Strassen primality test on p. The probability of a false
 // positive is at most 2^(-n).
 func Is(p *big.Int, n int) (bool, error) {
+	return IsContext(context.Background(), p, n)
+}
+
+// IsContext is like Is, but returns the context error if ctx is done before the test
+// completes.
+func IsContext(ctx context.Context, p *big.Int, n int) (bool, error) {
+	if p.Cmp(two) <= 0 || p.Bit(0) == 0 {
+		return p.Cmp(two) == 0, nil
+	}
+	if hasSmallFactor(p, sievePrimes[:quickPrimes]) {
+		return false, nil
+	}
+
 	p = new(big.Int).Set(p)
 	limit := new(big.Int).Sub(p, two)
 
@@ -73,13 +135,16 @@ func Is(p *big.Int, n int) (bool, error) {
 	pow.Sub(pow, one).Rsh(pow, 1)
 
 	for i := 0; i < n; i++ {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
 		a, err := rand.Int(limit)
 		if err != nil {
 			return false, err
 		}
 		a.Add(a, two) // a is random in [2,p)
 
-		j := Jacobi(a, p)
+		j := jacobi(a, p)
 		if j == 0 {
 			return false, nil
 		}
@@ -95,9 +160,59 @@ func Is(p *big.Int, n int) (bool, error) {
 	return true, nil
 }
 
-// Jacobi computes the Jacobi symbol of a and b.
-func Jacobi(a, b *big.Int) int {
-	a = new(big.Int).Set(a)
+// Jacobi computes the Jacobi symbol of a and b. It returns ErrJacobiModulus if b is
+// not odd and positive.
+func Jacobi(a, b *big.Int) (int, error) {
+	if b.Sign() <= 0 || b.Bit(0) == 0 {
+		return 0, ErrJacobiModulus
+	}
+	return jacobi(a, b), nil
+}
+
+// Legendre computes the Legendre symbol of a and the odd prime p. The primality of p
+// is not checked, so for composite p this is the Jacobi symbol. It returns
+// ErrJacobiModulus if p is not odd and positive.
+func Legendre(a, p *big.Int) (int, error) {
+	return Jacobi(a, p)
+}
+
+// Kronecker computes the Kronecker symbol of a and b, which extends the Jacobi symbol
+// to all integers b.
+func Kronecker(a, b *big.Int) int {
+	if b.Sign() == 0 {
+		if a.CmpAbs(one) == 0 {
+			return 1
+		}
+		return 0
+	}
+
+	s := 1
+	if b.Sign() < 0 && a.Sign() < 0 {
+		s = -s // (a/-1) = -1 for negative a
+	}
+
+	// (a/2) is 0 for even a, and otherwise -1 if a = 3 or 5 (mod 8).
+	i := b.TrailingZeroBits()
+	if i > 0 {
+		if a.Bit(0) == 0 {
+			return 0
+		}
+		if m := new(big.Int).And(a, big.NewInt(7)).Uint64(); i&1 == 1 && (m == 3 || m == 5) {
+			s = -s
+		}
+	}
+	c := new(big.Int).Rsh(new(big.Int).Abs(b), i)
+	return s * jacobi(a, c)
+}
+
+// jacobi computes the Jacobi symbol of a and the odd, positive b.
+func jacobi(a, b *big.Int) int {
+	if b.BitLen() <= 64 {
+		return jacobiWord(new(big.Int).Mod(a, b).Uint64(), b.Uint64())
+	}
+
+	// All computations for the Jacobi are done in the (mod b) space.
+	a = new(big.Int).Mod(a, b)
 	b = new(big.Int).Set(b)
 
 	var (
@@ -105,13 +220,8 @@ func Jacobi(a, b *big.Int) int {
 		c = new(big.Int)
 	)
 
-	for {
-		if b.Cmp(one) == 0 || a.Cmp(one) == 0 {
-			return s
-		}
-		// All computations for the Jacobi are done in the (mod b) space.
-		a.Mod(a, b)
-		if a.Cmp(zero) == 0 {
+	for b.BitLen() > 64 {
+		if a.Sign() == 0 {
 			return 0
 		}
 
@@ -135,9 +245,31 @@ func Jacobi(a, b *big.Int) int {
 			s = -s
 		}
 
-		a.Set(b)
+		a.Mod(b, c)
 		b.Set(c)
 	}
+	return s * jacobiWord(a.Uint64(), b.Uint64())
+}
+
+// jacobiWord is jacobi for word sized operands with a < b.
+func jacobiWord(a, b uint64) int {
+	s := 1
+	for b != 1 {
+		if a == 0 {
+			return 0
+		}
+
+		i := bits.TrailingZeros64(a)
+		a >>= uint(i)
+		if m := b & 7; i&1 == 1 && (m == 3 || m == 5) {
+			s = -s
+		}
+		if a&3 == 3 && b&3 == 3 {
+			s = -s
+		}
+		a, b = b%a, a
+	}
+	return s
 }
 
 // Find the number of trailing zeros in a to do one `n` shift instead of
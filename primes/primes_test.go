@@ -11,11 +11,21 @@ import (
 const iters = 1000
 
 func TestJacobi(t *testing.T) {
-	for i := int64(0); i < iters; i++ {
+	for i := int64(-iters); i < iters; i++ {
 		for j := int64(1); j < iters; j += 2 {
 			assertJacobi(t, big.NewInt(i), big.NewInt(j))
 		}
 	}
+
+	for _, s := range []string{"0", "-1", "2", "-3", "18446744073709551616"} {
+		b, _ := new(big.Int).SetString(s, 10)
+		if _, err := Jacobi(big.NewInt(5), b); err != ErrJacobiModulus {
+			t.Fatalf("Expected ErrJacobiModulus for J(5, %s), got %v", b, err)
+		}
+		if _, err := Legendre(big.NewInt(5), b); err != ErrJacobiModulus {
+			t.Fatalf("Expected ErrJacobiModulus for L(5, %s), got %v", b, err)
+		}
+	}
 }
 
 var (
@@ -26,6 +36,82 @@ func TestJacobiLarge(t *testing.T) {
 	for n := 0; n < iters; n++ {
 		i, j := randInputs(384)
 		assertJacobi(t, i, j)
+		assertJacobi(t, i.Neg(i), j)
+	}
+
+	// Operands around the word size switch to the word sized fast path.
+	for _, bits := range []uint{63, 64, 65, 128} {
+		for n := 0; n < iters; n++ {
+			i, j := randInputs(bits)
+			j.SetBit(j, int(bits-1), 1)
+			assertJacobi(t, i, j)
+		}
+	}
+	max := new(big.Int).Lsh(one, 64)
+	assertJacobi(t, new(big.Int).Sub(max, two), new(big.Int).Sub(max, one))
+	assertJacobi(t, new(big.Int).Sub(max, one), new(big.Int).Add(max, one))
+}
+
+func TestKronecker(t *testing.T) {
+	for i := int64(-200); i < 200; i++ {
+		for j := int64(-200); j < 200; j++ {
+			a, b := big.NewInt(i), big.NewInt(j)
+			if j%2 != 0 {
+				if actual, exp := Kronecker(a, b), big.Jacobi(a, b); actual != exp {
+					t.Fatalf("Expected K(%d, %d) = %d, got %d", i, j, exp, actual)
+				}
+				continue
+			}
+
+			// K(a, b) = K(a, b/2)K(a, 2) for even, non-zero b.
+			exp := 0
+			switch {
+			case j == 0 && (i == 1 || i == -1):
+				exp = 1
+			case j == 0 || i%2 == 0:
+			case i&7 == 1 || i&7 == 7:
+				exp = Kronecker(a, big.NewInt(j/2))
+			default:
+				exp = -Kronecker(a, big.NewInt(j/2))
+			}
+			if actual := Kronecker(a, b); actual != exp {
+				t.Fatalf("Expected K(%d, %d) = %d, got %d", i, j, exp, actual)
+			}
+		}
+	}
+
+	for n := 0; n < iters; n++ {
+		i, j := randInputs(256)
+		if n%2 == 0 {
+			j.Neg(j)
+		}
+		if actual, exp := Kronecker(i, j), big.Jacobi(i, j); actual != exp {
+			t.Fatalf("Expected K(%d, %d) = %d, got %d", i, j, exp, actual)
+		}
+	}
+}
+
+func TestLegendre(t *testing.T) {
+	p, err := Find(128, 20)
+	if err != nil {
+		t.Fatalf("Failed to find prime: %v", err)
+	}
+	for n := 0; n < iters; n++ {
+		a := new(big.Int).Rand(r, p)
+		l, err := Legendre(a, p)
+		if err != nil {
+			t.Fatalf("Failed to compute Legendre symbol: %v", err)
+		}
+
+		// Euler's criterion: a^((p-1)/2) = (a/p) (mod p)
+		e := new(big.Int).Rsh(p, 1)
+		exp := new(big.Int).Exp(a, e, p)
+		if exp.Cmp(new(big.Int).Sub(p, one)) == 0 {
+			exp.SetInt64(-1)
+		}
+		if int64(l) != exp.Int64() {
+			t.Fatalf("Expected L(%d, %d) = %d, got %d", a, p, exp, l)
+		}
 	}
 }
 
@@ -129,7 +215,10 @@ func benchmarkStdlibFind(bits int, b *testing.B) {
 }
 
 func assertJacobi(t *testing.T, i, j *big.Int) {
-	actual := Jacobi(new(big.Int).Set(i), new(big.Int).Set(j))
+	actual, err := Jacobi(new(big.Int).Set(i), new(big.Int).Set(j))
+	if err != nil {
+		t.Fatalf("Failed to compute J(%d, %d): %v", i, j, err)
+	}
 	exp := big.Jacobi(new(big.Int).Set(i), new(big.Int).Set(j))
 	if actual != exp {
 		t.Fatalf("Expected, J(%d, %d) = %d, got %d", i, j, exp, actual)
@@ -0,0 +1,265 @@
+package primes
+
+import (
+	"crypto/sha256"
+	"errors"
+	"math/big"
+
+	"github.com/mmussomele/crypto/rand"
+)
+
+// ErrInvalidCertificate is returned when a primality certificate does not prove
+// that its number is prime.
+var ErrInvalidCertificate = errors.New("crypto/primes: invalid primality certificate")
+
+// A Certificate proves that N is prime.
+//
+// If A is nil, N is smaller than 2^32 and is proven prime by trial division.
+// Otherwise Factors prove that some distinct primes q divide N-1, F is the product
+// of the largest powers of those primes dividing N-1, and A is a witness for which
+// A^(N-1) = 1 (mod N) and gcd(A^((N-1)/q)-1, N) = 1 for every q. Then N is prime by
+// Pocklington's theorem if F^2 > N, or by the Brillhart-Lehmer-Selfridge theorem if
+// F^3 >= N and c1^2-4c2 is not a square, where N = c2F^2 + c1F + 1 with c1 < F.
+//
+// When Factors contain every prime dividing N-1, the certificate is a Pratt
+// certificate.
+type Certificate struct {
+	N       *big.Int
+	A       *big.Int
+	Factors []*Certificate
+}
+
+// VerifyCertificate checks that c proves that c.N is prime. It returns
+// ErrInvalidCertificate if it does not.
+func VerifyCertificate(c *Certificate) error {
+	if c == nil || c.N == nil {
+		return ErrInvalidCertificate
+	}
+	if c.A == nil {
+		if !trialDivision(c.N) {
+			return ErrInvalidCertificate
+		}
+		return nil
+	}
+
+	n := c.N
+	n1 := new(big.Int).Sub(n, one)
+	if n.Cmp(three) < 0 || c.A.Sign() <= 0 || c.A.Cmp(n) >= 0 {
+		return ErrInvalidCertificate
+	}
+	if new(big.Int).Exp(c.A, n1, n).Cmp(one) != 0 {
+		return ErrInvalidCertificate
+	}
+
+	f := big.NewInt(1)
+	r := new(big.Int).Set(n1)
+	m, e, g := new(big.Int), new(big.Int), new(big.Int)
+	for _, fc := range c.Factors {
+		if err := VerifyCertificate(fc); err != nil {
+			return err
+		}
+
+		q := fc.N
+		if m.Mod(r, q).Sign() != 0 {
+			return ErrInvalidCertificate // q does not divide N-1, or is repeated
+		}
+		for m.Mod(r, q).Sign() == 0 {
+			r.Div(r, q)
+			f.Mul(f, q)
+		}
+
+		e.Div(n1, q)
+		g.Exp(c.A, e, n).Sub(g, one)
+		if g.GCD(nil, nil, g, n).Cmp(one) != 0 {
+			return ErrInvalidCertificate
+		}
+	}
+
+	if m.Mul(f, f).Cmp(n) > 0 {
+		return nil
+	}
+	if m.Mul(m, f).Cmp(n) < 0 {
+		return ErrInvalidCertificate
+	}
+
+	// N-1 = (c2F + c1)F with c1 < F, and every prime factor of N is 1 (mod F), so N
+	// has at most two prime factors, and it is composite if and only if c1^2-4c2 is
+	// a square.
+	c2, c1 := new(big.Int).DivMod(r, f, new(big.Int))
+	d := c1.Mul(c1, c1)
+	d.Sub(d, c2.Lsh(c2, 2))
+	if d.Sign() >= 0 && m.Sqrt(d).Mul(m, m).Cmp(d) == 0 {
+		return ErrInvalidCertificate
+	}
+	return nil
+}
+
+// FindProvable finds a random prime of exactly b bits with the Shawe-Taylor
+// construction, together with a certificate proving that it is prime. b must be at
+// least 2.
+func FindProvable(b int) (*big.Int, *Certificate, error) {
+	seed := make([]byte, 32)
+	for {
+		if _, err := rand.Read(seed); err != nil {
+			return nil, nil, err
+		}
+		switch p, c, err := ShaweTaylor(b, seed); err {
+		case nil:
+			return p, c, nil
+		case ErrNoPrime:
+			continue // the seed was unlucky
+		default:
+			return nil, nil, err
+		}
+	}
+}
+
+// ShaweTaylor generates a prime of exactly b bits from seed with the Shawe-Taylor
+// random prime routine of FIPS 186-5 Appendix A.1.2.1, using SHA-256, and returns it
+// together with a certificate proving that it is prime. The same seed always gives
+// the same prime. It returns ErrNoPrime if the routine fails for the given seed. b
+// must be at least 2.
+func ShaweTaylor(b int, seed []byte) (*big.Int, *Certificate, error) {
+	if b < 2 {
+		panic("crypto/primes: provable primes must have at least 2 bits")
+	}
+	s := &stSeed{
+		v:   new(big.Int).SetBytes(seed),
+		mod: new(big.Int).Lsh(one, uint(8*len(seed))),
+		buf: make([]byte, len(seed)),
+	}
+	c := shaweTaylor(b, s)
+	if c == nil {
+		return nil, nil, ErrNoPrime
+	}
+	return c.N, c, nil
+}
+
+// stSeed is the prime_seed of the Shawe-Taylor routine, an integer of a fixed number
+// of bytes.
+type stSeed struct {
+	v, mod *big.Int
+	buf    []byte
+}
+
+// hash returns the SHA-256 hash of seed+i as an integer.
+func (s *stSeed) hash(i int) *big.Int {
+	v := new(big.Int).Add(s.v, big.NewInt(int64(i)))
+	v.Mod(v, s.mod)
+	for j := range s.buf {
+		s.buf[j] = 0
+	}
+	vb := v.Bytes()
+	copy(s.buf[len(s.buf)-len(vb):], vb)
+
+	h := sha256.Sum256(s.buf)
+	return v.SetBytes(h[:])
+}
+
+// hashes returns sum(hash(i)*2^(256i)) for i in [0, n] and adds n+1 to the seed.
+func (s *stSeed) hashes(n int) *big.Int {
+	x := new(big.Int)
+	for i := n; i >= 0; i-- {
+		x.Lsh(x, 8*sha256.Size).Add(x, s.hash(i))
+	}
+	s.add(n + 1)
+	return x
+}
+
+func (s *stSeed) add(i int) {
+	s.v.Add(s.v, big.NewInt(int64(i))).Mod(s.v, s.mod)
+}
+
+// trialPrimes is the number of sieve primes used to reject Shawe-Taylor candidates.
+const trialPrimes = 512
+
+// shaweTaylor runs the Shawe-Taylor routine for a prime of b bits. It returns nil
+// if the routine fails.
+func shaweTaylor(b int, s *stSeed) *Certificate {
+	low := new(big.Int).Lsh(one, uint(b-1))
+	if b < 33 {
+		for counter := 0; counter <= 4*b; counter++ {
+			c := s.hash(0)
+			c.Xor(c, s.hash(1))
+			s.add(2)
+
+			// c = 2^(b-1) + (c mod 2^(b-1)), made odd
+			c.Mod(c, low).Add(c, low).SetBit(c, 0, 1)
+			if trialDivision(c) {
+				return &Certificate{N: c}
+			}
+		}
+		return nil
+	}
+
+	c0 := shaweTaylor((b+1)/2+1, s)
+	if c0 == nil {
+		return nil
+	}
+
+	// x = 2^(b-1) + (x mod 2^(b-1)), t = ceil(x/(2c0))
+	iterations := (b+8*sha256.Size-1)/(8*sha256.Size) - 1
+	x := s.hashes(iterations)
+	x.Mod(x, low).Add(x, low)
+
+	c02 := new(big.Int).Lsh(c0.N, 1)
+	t := ceilDiv(x, c02)
+	high := new(big.Int).Lsh(one, uint(b))
+
+	c, z, g := new(big.Int), new(big.Int), new(big.Int)
+	for counter := 0; counter < 4*b; counter++ {
+		// c = 2tc0+1, restarting from the bottom of the range if it is too large
+		if c.Mul(t, c02).Add(c, one).Cmp(high) > 0 {
+			t = ceilDiv(low, c02)
+			c.Mul(t, c02).Add(c, one)
+		}
+
+		// Composite candidates always fail the test below, so the seed is advanced
+		// without testing candidates with small factors. This does not change the
+		// result.
+		if hasSmallFactor(c, sievePrimes[:trialPrimes]) {
+			s.add(iterations + 1)
+			t.Add(t, one)
+			continue
+		}
+
+		// a is in [2, c-2]
+		a := s.hashes(iterations)
+		a.Mod(a, g.Sub(c, three)).Add(a, two)
+
+		z.Exp(a, g.Lsh(t, 1), c)
+		if g.Sub(z, one).GCD(nil, nil, g, c).Cmp(one) == 0 && g.Exp(z, c0.N, c).Cmp(one) == 0 {
+			return &Certificate{N: c, A: a, Factors: []*Certificate{c0}}
+		}
+		t.Add(t, one)
+	}
+	return nil
+}
+
+// ceilDiv returns ceil(x/y) for positive x and y.
+func ceilDiv(x, y *big.Int) *big.Int {
+	q := new(big.Int).Add(x, y)
+	q.Sub(q, one)
+	return q.Div(q, y)
+}
+
+// trialDivision reports whether n is a prime smaller than 2^32 by trial division.
+func trialDivision(n *big.Int) bool {
+	if n.Sign() <= 0 || n.BitLen() > 32 {
+		return false
+	}
+	v := n.Uint64()
+	if v < 2 || v != 2 && v%2 == 0 {
+		return v == 2
+	}
+	for _, p := range sievePrimes {
+		q := uint64(p)
+		if q*q > v {
+			break
+		}
+		if v%q == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+package primes
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+func TestShaweTaylor(t *testing.T) {
+	seed := bytes.Repeat([]byte{0x5a}, 32)
+	for _, bits := range []int{2, 3, 16, 32, 33, 64, 100, 256, 521, 1024} {
+		p, c, err := ShaweTaylor(bits, seed)
+		if err == ErrNoPrime {
+			continue // possible, if unlikely, for a fixed seed
+		}
+		if err != nil {
+			t.Fatalf("Failed to generate provable prime: %v", err)
+		}
+		assertProvable(t, p, c, bits)
+
+		// The construction is deterministic.
+		q, _, err := ShaweTaylor(bits, seed)
+		if err != nil || p.Cmp(q) != 0 {
+			t.Fatalf("Expected the same prime for the same seed, got %s and %s (%v)", p, q, err)
+		}
+	}
+}
+
+func TestFindProvable(t *testing.T) {
+	for _, bits := range []int{40, 512, 2048} {
+		p, c, err := FindProvable(bits)
+		if err != nil {
+			t.Fatalf("Failed to generate provable prime: %v", err)
+		}
+		assertProvable(t, p, c, bits)
+	}
+}
+
+func TestVerifyCertificateInvalid(t *testing.T) {
+	_, c, err := FindProvable(256)
+	if err != nil {
+		t.Fatalf("Failed to generate provable prime: %v", err)
+	}
+
+	// The witness and factors can not be moved to a different number.
+	bad := *c
+	bad.N = new(big.Int).Add(c.N, two)
+	assertInvalid(t, &bad)
+
+	bad = *c
+	bad.A = new(big.Int).Add(c.A, one)
+	if VerifyCertificate(&bad) == nil {
+		// Some other witness may also work, but not the number 1.
+		bad.A = big.NewInt(1)
+		assertInvalid(t, &bad)
+	}
+
+	bad = *c
+	bad.Factors = nil
+	assertInvalid(t, &bad)
+
+	bad = *c
+	bad.Factors = []*Certificate{c.Factors[0], c.Factors[0]}
+	assertInvalid(t, &bad)
+
+	// Composites and large numbers can not be proven by trial division.
+	assertInvalid(t, &Certificate{N: big.NewInt(91)})
+	assertInvalid(t, &Certificate{N: big.NewInt(1)})
+	assertInvalid(t, &Certificate{N: c.N})
+	assertInvalid(t, nil)
+
+	// A Fermat liar for a Carmichael number is not a Pocklington witness.
+	assertInvalid(t, &Certificate{
+		N:       big.NewInt(561),
+		A:       big.NewInt(2),
+		Factors: []*Certificate{{N: big.NewInt(2)}, {N: big.NewInt(5)}, {N: big.NewInt(7)}},
+	})
+}
+
+func BenchmarkFindProvable1024(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		FindProvable(1024)
+	}
+}
+
+func assertProvable(t *testing.T, p *big.Int, c *Certificate, bits int) {
+	t.Helper()
+	switch {
+	case p.BitLen() != bits:
+		t.Fatalf("Expected %d bits, got %d", bits, p.BitLen())
+	case c.N.Cmp(p) != 0:
+		t.Fatalf("Certificate is for %s, not %s", c.N, p)
+	case !p.ProbablyPrime(32):
+		t.Fatalf("Provable prime %s is composite", p)
+	}
+	if err := VerifyCertificate(c); err != nil {
+		t.Fatalf("Failed to verify certificate for %s: %v", p, err)
+	}
+}
+
+func assertInvalid(t *testing.T, c *Certificate) {
+	t.Helper()
+	if err := VerifyCertificate(c); err != ErrInvalidCertificate {
+		t.Fatalf("Expected ErrInvalidCertificate, got %v", err)
+	}
+}
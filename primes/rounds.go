@@ -0,0 +1,67 @@
+package primes
+
+import (
+	"math"
+	"math/big"
+)
+
+// Rounds returns the number of Miller-Rabin rounds with random bases needed for the
+// probability that a random odd b-bit candidate passing them is composite to be at
+// most 2^(-n). It uses the average-case bound of Damgard, Landrock and Pomerance, as
+// in FIPS 186-5 Appendix C.1, and never exceeds the (n+1)/2 rounds needed for an
+// arbitrary input.
+//
+// The bound only holds for independent, uniformly random candidates. It does not
+// hold for inputs chosen by an adversary or for the candidates of an incremental
+// search, which must be tested with MillerRabin or Is instead.
+func Rounds(b, n int) int {
+	worst := (n + 1) / 2
+	for t := 1; t < worst; t++ {
+		if averageCaseError(b, t) <= -float64(n) {
+			return t
+		}
+	}
+	return worst
+}
+
+// averageCaseError returns the base 2 logarithm of the Damgard-Landrock-Pomerance bound
+// on the probability that a random odd b-bit number passing t Miller-Rabin rounds is
+// composite, or +Inf if it does not apply to b.
+func averageCaseError(b, t int) float64 {
+	// p(b,t) <= 2.00743*ln(2)*b*2^-b * (2^(b-2-Mt) + 8(pi^2-6)/3 * 2^(b-2) * S(M)),
+	// S(M) = sum_{m=3}^{M} sum_{j=2}^{m} 2^(m-(m-1)t-j-(b-1)/j), minimized over the M
+	// with 3 <= M <= 2*sqrt(b-1)-1. S(M) is accumulated as 2^max*sum, so that the
+	// terms do not underflow.
+	k, tf := float64(b), float64(t)
+	c := math.Log2(8*(math.Pi*math.Pi-6)/3) + k - 2
+	best := math.Inf(1)
+	max, sum := math.Inf(-1), 0.0
+	add := func(max, sum, e float64) (float64, float64) {
+		if e > max {
+			return e, sum*math.Exp2(max-e) + 1
+		}
+		return max, sum + math.Exp2(e-max)
+	}
+	for m := 3; float64(m) <= 2*math.Sqrt(k-1)-1; m++ {
+		fm := float64(m)
+		for j := 2; j <= m; j++ {
+			fj := float64(j)
+			max, sum = add(max, sum, fm-(fm-1)*tf-fj-(k-1)/fj)
+		}
+
+		tmax, tsum := add(c+max, sum, k-2-fm*tf)
+		p := math.Log2(2.00743*math.Ln2*k) - k + tmax + math.Log2(tsum)
+		best = math.Min(best, p)
+	}
+	return best
+}
+
+// AverageCase performs a Miller-Rabin primality test on p with the number of rounds
+// given by Rounds for the size of p. The probability that a random candidate it
+// reports as prime is composite is at most 2^(-n). It is meant for testing random
+// candidates during prime generation, and must not be used on inputs chosen by an
+// adversary, or on the candidates of an incremental search such as Find, which are
+// not independent.
+func AverageCase(p *big.Int, n int) (bool, error) {
+	return millerRabin(p, Rounds(p.BitLen(), n))
+}
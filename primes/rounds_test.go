@@ -0,0 +1,56 @@
+package primes
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestRounds(t *testing.T) {
+	// FIPS 186-5 Appendix C, Tables C.1 and C.3.
+	for _, c := range []struct{ bits, n, rounds int }{
+		{160, 80, 19}, {224, 112, 24}, {256, 128, 27},
+		{1024, 80, 3}, {2048, 112, 3}, {3072, 128, 2},
+		{512, 100, 7}, {1024, 100, 4}, {1536, 100, 3},
+	} {
+		if r := Rounds(c.bits, c.n); r != c.rounds {
+			t.Fatalf("Expected %d rounds for %d bits and 2^-%d, got %d", c.rounds, c.bits, c.n, r)
+		}
+	}
+
+	// Small candidates fall back to the worst case bound, and more bits or a larger
+	// error never need more rounds.
+	for _, b := range []int{2, 5, 16} {
+		if r := Rounds(b, 64); r != 32 {
+			t.Fatalf("Expected 32 rounds for %d bits, got %d", b, r)
+		}
+	}
+	for b := 64; b < 4096; b += 64 {
+		if Rounds(b+64, 128) > Rounds(b, 128) || Rounds(b, 100) > Rounds(b, 128) {
+			t.Fatalf("Rounds is not monotonic at %d bits", b)
+		}
+	}
+}
+
+func TestAverageCase(t *testing.T) {
+	for i := int64(0); i < 1000; i++ {
+		assertTest(t, AverageCase, big.NewInt(i))
+	}
+	for i := 0; i < iters; i++ {
+		j, _ := randInputs(512)
+		assertTest(t, AverageCase, j)
+	}
+
+	p, err := FindWith(AverageCase, 1024, 128)
+	if err != nil {
+		t.Fatalf("Failed to find prime: %v", err)
+	}
+	if !p.ProbablyPrime(20) {
+		t.Fatalf("%s is not prime", p)
+	}
+}
+
+func BenchmarkFindAverageCase1024(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		FindWith(AverageCase, 1024, 128)
+	}
+}
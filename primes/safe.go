@@ -0,0 +1,123 @@
+package primes
+
+import (
+	"math/big"
+	"runtime"
+
+	"github.com/mmussomele/crypto/rand"
+)
+
+// FindSafe finds a random safe prime p of exactly b bits, such that (p-1)/2 is also
+// prime. The probability that either number is not prime is at most 2^(-n). b must
+// be at least 3.
+func FindSafe(b, n int) (*big.Int, error) {
+	return FindSafeWith(Is, b, n)
+}
+
+// FindSafeWith is like FindSafe, but uses the primality test t.
+func FindSafeWith(t Test, b, n int) (*big.Int, error) {
+	return findSafe(t, b, n, nil)
+}
+
+// FindSafeParallel is like FindSafe, but searches on the given number of goroutines.
+// If workers is not positive, GOMAXPROCS goroutines are used.
+func FindSafeParallel(b, n, workers int) (*big.Int, error) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	type result struct {
+		p   *big.Int
+		err error
+	}
+	results := make(chan result, workers)
+	done := make(chan struct{})
+	defer close(done)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			p, err := findSafe(Is, b, n, done)
+			results <- result{p, err}
+		}()
+	}
+	r := <-results
+	return r.p, r.err
+}
+
+// FindSophieGermain finds a random Sophie Germain prime q of exactly b bits, such that
+// 2q+1 is also prime. The probability that either number is not prime is at most
+// 2^(-n). b must be at least 2.
+func FindSophieGermain(b, n int) (*big.Int, error) {
+	p, err := FindSafe(b+1, n)
+	if err != nil {
+		return nil, err
+	}
+	return p.Rsh(p, 1), nil
+}
+
+// findSafe searches for a safe prime of b bits until one is found or done is closed,
+// in which case it returns nil.
+func findSafe(t Test, b, n int, done <-chan struct{}) (*big.Int, error) {
+	if b < 3 {
+		panic("crypto/primes: safe primes must have at least 3 bits")
+	}
+
+	// q is in [2^(b-2), 2^(b-1)), so p = 2q+1 has exactly b bits.
+	limit := new(big.Int).Lsh(one, uint(b-1))
+	span := new(big.Int).Rsh(limit, 1)
+	for {
+		q, err := rand.Int(span)
+		if err != nil {
+			return nil, err
+		}
+		q.Add(q, span).SetBit(q, 0, 1)
+
+		sv := newSafeSieve(q, 2)
+		for q.Cmp(limit) < 0 {
+			for i, comp := range sv.comp {
+				select {
+				case <-done:
+					return nil, nil
+				default:
+				}
+
+				sv.candidate(q, i)
+				if q.Cmp(limit) >= 0 {
+					break
+				}
+				if comp {
+					continue
+				}
+
+				switch ok, err := isSafe(t, q, n); {
+				case err != nil:
+					return nil, err
+				case ok:
+					return q.Lsh(q, 1).Add(q, one), nil
+				}
+			}
+			sv.advance()
+		}
+	}
+}
+
+// isSafe reports whether q and 2q+1 are both prime.
+func isSafe(t Test, q *big.Int, n int) (bool, error) {
+	p1 := new(big.Int).Lsh(q, 1)
+	p := new(big.Int).Add(p1, one)
+
+	// Cheap tests to base 2 reject almost all composites before running t.
+	if q.Cmp(three) > 0 {
+		if new(big.Int).Exp(two, p1, p).Cmp(one) != 0 {
+			return false, nil
+		}
+		if !strongProbablePrime(q, two) {
+			return false, nil
+		}
+	}
+
+	if ok, err := t(q, n); !ok || err != nil {
+		return false, err
+	}
+	return t(p, n)
+}
@@ -0,0 +1,98 @@
+package primes
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestFindSafeSmall(t *testing.T) {
+	for bits := 3; bits <= 64; bits++ {
+		p, err := FindSafe(bits, 64)
+		if err != nil {
+			t.Fatalf("Failed to find safe prime: %v", err)
+		}
+		assertSafe(t, p, bits)
+	}
+}
+
+func TestFindSafe(t *testing.T) {
+	const bits = 512
+	p, err := FindSafe(bits, 64)
+	if err != nil {
+		t.Fatalf("Failed to find safe prime: %v", err)
+	}
+	assertSafe(t, p, bits)
+}
+
+func TestFindSafeParallel(t *testing.T) {
+	const bits = 768
+	p, err := FindSafeParallel(bits, 64, 0)
+	if err != nil {
+		t.Fatalf("Failed to find safe prime: %v", err)
+	}
+	assertSafe(t, p, bits)
+}
+
+func TestFindSophieGermain(t *testing.T) {
+	const bits = 256
+	q, err := FindSophieGermain(bits, 64)
+	if err != nil {
+		t.Fatalf("Failed to find Sophie Germain prime: %v", err)
+	}
+	p := new(big.Int).Lsh(q, 1)
+	assertSafe(t, p.Add(p, one), bits+1)
+}
+
+func TestSafeSieve(t *testing.T) {
+	// Every candidate surviving the sieve must have no small factor in q or 2q+1,
+	// and every safe prime must survive.
+	sv := newSafeSieve(big.NewInt(3), 2)
+	c, p := new(big.Int), new(big.Int)
+	for i, comp := range sv.comp {
+		sv.candidate(c, i)
+		p.Lsh(c, 1).Add(p, one)
+		if safe := c.ProbablyPrime(20) && p.ProbablyPrime(20); safe && comp {
+			t.Fatalf("Safe prime %s was sieved", p)
+		}
+		if comp {
+			continue
+		}
+		for _, s := range sievePrimes {
+			m := uint64(s)
+			if c.Uint64()%m == 0 && c.Uint64() != m || p.Uint64()%m == 0 && p.Uint64() != m {
+				t.Fatalf("Candidate %s survived with factor %d", c, s)
+			}
+		}
+	}
+}
+
+func BenchmarkFindSafe256(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		FindSafe(256, 40)
+	}
+}
+
+func BenchmarkFindSafe512(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		FindSafe(512, 40)
+	}
+}
+
+func BenchmarkFindSafeParallel512(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		FindSafeParallel(512, 40, 0)
+	}
+}
+
+func assertSafe(t *testing.T, p *big.Int, bits int) {
+	t.Helper()
+	q := new(big.Int).Rsh(p, 1)
+	switch {
+	case p.BitLen() != bits:
+		t.Fatalf("Expected %d bits, got %d", bits, p.BitLen())
+	case !p.ProbablyPrime(32):
+		t.Fatalf("Safe prime %s is composite", p)
+	case !q.ProbablyPrime(32):
+		t.Fatalf("(p-1)/2 = %s is composite", q)
+	}
+}
@@ -0,0 +1,196 @@
+package primes
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mmussomele/crypto/rand"
+)
+
+// Stats counts the work done by a search. The counters are updated atomically and
+// may be read with atomic.LoadUint64 while a search is running.
+type Stats struct {
+	// Candidates is the number of candidates considered, including those rejected
+	// by the sieve.
+	Candidates uint64
+
+	// Tests is the number of candidates the primality test was run on.
+	Tests uint64
+}
+
+func (s *Stats) add(candidates, tests uint64) {
+	if s != nil {
+		atomic.AddUint64(&s.Candidates, candidates)
+		atomic.AddUint64(&s.Tests, tests)
+	}
+}
+
+// SearchOptions configures a parallel search. The zero value selects the defaults.
+type SearchOptions struct {
+	// Test is the primality test. The default is Is.
+	Test Test
+
+	// Workers is the number of goroutines searching. The default is GOMAXPROCS.
+	Workers int
+
+	// Stats, if not nil, is updated with the work done by the search.
+	Stats *Stats
+}
+
+func (o *SearchOptions) withDefaults() SearchOptions {
+	var d SearchOptions
+	if o != nil {
+		d = *o
+	}
+	if d.Test == nil {
+		d.Test = Is
+	}
+	if d.Workers <= 0 {
+		d.Workers = runtime.GOMAXPROCS(0)
+	}
+	return d
+}
+
+// FindContext is like Find, but searches in parallel as configured by opts, which may
+// be nil. If ctx is done before a prime is found, it returns the context error.
+func FindContext(ctx context.Context, b, n int, opts *SearchOptions) (*big.Int, error) {
+	buf := make([]byte, (b+7)/8)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	p := new(big.Int).SetBytes(buf)
+	if p.BitLen() < b {
+		p.SetBit(p, b-1, 1) // Ensure p is at least b bits
+	}
+	return FindNextContext(ctx, p, n, opts)
+}
+
+// FindNextContext is like FindNext, but searches in parallel as configured by opts,
+// which may be nil. It returns the same prime as a sequential search. If ctx is done
+// before the prime is found, it returns the context error.
+func FindNextContext(ctx context.Context, s *big.Int, n int, opts *SearchOptions) (*big.Int, error) {
+	if s.Cmp(two) <= 0 {
+		return big.NewInt(2), nil
+	}
+	return searchParallel(ctx, new(big.Int).SetBit(s, 0, 1), 2, n, opts)
+}
+
+// FindPreviousContext is like FindPrevious, but searches in parallel as configured by
+// opts, which may be nil. It returns the same prime as a sequential search. If ctx is
+// done before the prime is found, it returns the context error.
+func FindPreviousContext(ctx context.Context, s *big.Int, n int, opts *SearchOptions) (*big.Int, error) {
+	switch s.Cmp(two) {
+	case -1:
+		return nil, ErrNoPrime
+	case 0:
+		return big.NewInt(2), nil
+	}
+
+	s = new(big.Int).Set(s)
+	if s.Bit(0) == 0 {
+		s.Sub(s, one)
+	}
+	return searchParallel(ctx, s, -2, n, opts)
+}
+
+// searchParallel is like search, but shards the sieve windows between workers: worker
+// w searches windows w, w+workers, w+2*workers, ... Once a prime is found in window
+// k, the workers finish the windows before k and stop, and the first prime found
+// overall is returned.
+func searchParallel(ctx context.Context, s *big.Int, step int64, n int, opts *SearchOptions) (*big.Int, error) {
+	o := opts.withDefaults()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu          sync.Mutex
+		best        = int64(math.MaxInt64) // the window of result
+		bestIndex   int
+		result      *big.Int
+		err         error
+		interrupted = int64(math.MaxInt64) // the first window left unfinished
+		wg          sync.WaitGroup
+	)
+	stop := func(k int64) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return k > best
+	}
+	found := func(k int64, i int, p *big.Int) {
+		mu.Lock()
+		defer mu.Unlock()
+		if k < best || k == best && i < bestIndex {
+			best, bestIndex, result = k, i, p
+		}
+	}
+	fail := func(e error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err == nil {
+			err = e
+		}
+		cancel()
+	}
+
+	workers := int64(o.Workers)
+	for w := int64(0); w < workers; w++ {
+		wg.Add(1)
+		go func(w int64) {
+			defer wg.Done()
+			base := big.NewInt(w * sieveWindow * step)
+			sv := newSieve(base.Add(base, s), step)
+			for k := w; !stop(k); k += workers {
+				var candidates, tests uint64
+				for i, comp := range sv.comp {
+					if ctx.Err() != nil {
+						mu.Lock()
+						if k < interrupted {
+							interrupted = k
+						}
+						mu.Unlock()
+						o.Stats.add(candidates, tests)
+						return
+					}
+					candidates++
+					if comp {
+						continue
+					}
+					if stop(k) {
+						break // a prime was found in an earlier window
+					}
+
+					c := sv.candidate(new(big.Int), i)
+					if c.Cmp(three) < 0 {
+						found(k, i, big.NewInt(2)) // searching down reached 2
+						break
+					}
+					tests++
+					ok, e := o.Test(c, n)
+					if e != nil {
+						fail(e)
+						return
+					}
+					if ok {
+						found(k, i, c)
+						break
+					}
+				}
+				o.Stats.add(candidates, tests)
+				sv.advanceBy(workers)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	switch {
+	case err != nil:
+		return nil, err
+	case interrupted < best:
+		return nil, ctx.Err()
+	}
+	return result, nil
+}
@@ -0,0 +1,135 @@
+package primes
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFindNextContext(t *testing.T) {
+	ctx := context.Background()
+	for i := 0; i < 20; i++ {
+		s, _ := randInputs(256)
+		want, err := FindNext(s, 20)
+		if err != nil {
+			t.Fatalf("Failed to find prime: %v", err)
+		}
+		for _, workers := range []int{1, 3, 8} {
+			p, err := FindNextContext(ctx, s, 20, &SearchOptions{Workers: workers})
+			if err != nil {
+				t.Fatalf("Failed to find prime: %v", err)
+			}
+			if p.Cmp(want) != 0 {
+				t.Fatalf("Expected the next prime %s after %s, got %s", want, s, p)
+			}
+		}
+	}
+
+	p, err := FindContext(ctx, 512, 20, nil)
+	if err != nil {
+		t.Fatalf("Failed to find prime: %v", err)
+	}
+	if p.BitLen() < 512 || !p.ProbablyPrime(20) {
+		t.Fatalf("Expected a prime of at least 512 bits, got %s", p)
+	}
+}
+
+func TestSearchSharding(t *testing.T) {
+	// A test that rejects the primes near s makes the search cross many windows, so
+	// that every worker finds primes and the smallest must be chosen.
+	ctx := context.Background()
+	s := big.NewInt(1000000)
+	for _, step := range []int64{1, -1} {
+		limit := new(big.Int).Add(s, big.NewInt(step*20*2*sieveWindow))
+		far := func(p *big.Int, n int) (bool, error) {
+			if p.Cmp(limit)*int(step) < 0 {
+				return false, nil
+			}
+			return p.ProbablyPrime(n), nil
+		}
+
+		var want *big.Int
+		var err error
+		if step > 0 {
+			want, err = FindNextWith(far, s, 20)
+		} else {
+			want, err = FindPreviousWith(far, s, 20)
+		}
+		if err != nil {
+			t.Fatalf("Failed to find prime: %v", err)
+		}
+
+		for _, workers := range []int{1, 2, 7, 16} {
+			var stats Stats
+			opts := &SearchOptions{Test: far, Workers: workers, Stats: &stats}
+			var p *big.Int
+			if step > 0 {
+				p, err = FindNextContext(ctx, s, 20, opts)
+			} else {
+				p, err = FindPreviousContext(ctx, s, 20, opts)
+			}
+			if err != nil {
+				t.Fatalf("Failed to find prime: %v", err)
+			}
+			if p.Cmp(want) != 0 {
+				t.Fatalf("Expected %s with %d workers, got %s", want, workers, p)
+			}
+			if stats.Tests == 0 || stats.Candidates < stats.Tests || stats.Candidates < 20*sieveWindow {
+				t.Fatalf("Unexpected search statistics %+v", stats)
+			}
+		}
+	}
+
+	// Searching down past every candidate reaches 2.
+	never := func(*big.Int, int) (bool, error) { return false, nil }
+	p, err := FindPreviousContext(ctx, s, 20, &SearchOptions{Test: never, Workers: 4})
+	if err != nil || p.Cmp(two) != 0 {
+		t.Fatalf("Expected 2, got %v (%v)", p, err)
+	}
+}
+
+func TestSearchCancel(t *testing.T) {
+	var calls uint64
+	never := func(*big.Int, int) (bool, error) {
+		atomic.AddUint64(&calls, 1)
+		return false, nil
+	}
+	s := new(big.Int).Lsh(one, 256)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := FindNextContext(ctx, s, 20, &SearchOptions{Test: never}); err != context.Canceled {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := FindNextContext(ctx, s, 20, &SearchOptions{Test: never, Workers: 4}); err != context.DeadlineExceeded {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if atomic.LoadUint64(&calls) == 0 {
+		t.Fatalf("Expected the test to run before the deadline")
+	}
+
+	errTest := errors.New("test failure")
+	failing := func(*big.Int, int) (bool, error) { return false, errTest }
+	if _, err := FindNextContext(context.Background(), s, 20, &SearchOptions{Test: failing}); err != errTest {
+		t.Fatalf("Expected the test error, got %v", err)
+	}
+
+	p, _ := new(big.Int).SetString("170141183460469231731687303715884105727", 10)
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := IsContext(ctx, p, 20); err != context.Canceled {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func BenchmarkFindContext1024(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		FindContext(context.Background(), 1024, 40, nil)
+	}
+}
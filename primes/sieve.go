@@ -0,0 +1,162 @@
+package primes
+
+import (
+	"math/big"
+)
+
+// sievePrimes are the odd primes below 2^16, which are used to eliminate candidates
+// with small factors before running expensive primality tests. They are shared by the
+// sieves, trial division and the probabilistic tests.
+var sievePrimes = oddPrimesBelow(1 << 16)
+
+// quickPrimes is the number of sieve primes the probabilistic tests try by trial
+// division before testing a number.
+const quickPrimes = 64
+
+// sieveWindow is the number of candidates sieved at once.
+const sieveWindow = 4096
+
+// oddPrimesBelow returns the odd primes below n with the sieve of Eratosthenes.
+func oddPrimesBelow(n uint32) []uint32 {
+	composite := make([]bool, n)
+	var ps []uint32
+	for i := uint32(3); i < n; i += 2 {
+		if composite[i] {
+			continue
+		}
+		ps = append(ps, i)
+		for j := uint64(i) * uint64(i); j < uint64(n); j += 2 * uint64(i) {
+			composite[j] = true
+		}
+	}
+	return ps
+}
+
+// A sieve finds the candidates with small factors in windows of the arithmetic
+// progression base, base+step, base+2*step, ... The residues of base modulo the
+// sieve primes are computed once, after which the sieve advances without any big.Int
+// arithmetic.
+type sieve struct {
+	base  *big.Int
+	step  int64
+	res   []uint32 // base mod sievePrimes[i]
+	comp  []bool   // comp[i] is set if base+i*step has a small factor
+	small bool     // base+i*step may be one of the sieve primes
+	safe  bool     // also mark candidates q where 2q+1 has a small factor
+}
+
+// newSieve returns a sieve for the candidates base, base+step, ... step must be 2
+// or -2.
+func newSieve(base *big.Int, step int64) *sieve {
+	return newSieveOpt(base, step, false)
+}
+
+// newSafeSieve is like newSieve, but also marks the candidates q for which 2q+1 has
+// a small factor.
+func newSafeSieve(base *big.Int, step int64) *sieve {
+	return newSieveOpt(base, step, true)
+}
+
+func newSieveOpt(base *big.Int, step int64, safe bool) *sieve {
+	s := &sieve{
+		base: new(big.Int).Set(base),
+		step: step,
+		res:  make([]uint32, len(sievePrimes)),
+		comp: make([]bool, sieveWindow),
+		safe: safe,
+	}
+	s.small = s.base.BitLen() < 32
+
+	residues(s.res, s.base, sievePrimes)
+	s.fill()
+	return s
+}
+
+// residues sets res[i] to x mod ps[i]. x is reduced modulo products of several primes
+// that fit in a single word, and the residues are then computed with machine
+// arithmetic.
+func residues(res []uint32, x *big.Int, ps []uint32) {
+	m := new(big.Int)
+	r := new(big.Int)
+	for i := 0; i < len(ps); {
+		j, prod := i, uint64(1)
+		for ; j < len(ps) && prod <= (1<<64-1)/uint64(ps[j]); j++ {
+			prod *= uint64(ps[j])
+		}
+		rm := r.Mod(x, m.SetUint64(prod)).Uint64()
+		for ; i < j; i++ {
+			res[i] = uint32(rm % uint64(ps[i]))
+		}
+	}
+}
+
+// hasSmallFactor reports whether x is divisible by one of the odd primes ps and is
+// not equal to it.
+func hasSmallFactor(x *big.Int, ps []uint32) bool {
+	res := make([]uint32, len(ps))
+	residues(res, x, ps)
+	for i, r := range res {
+		if r == 0 && !(x.IsUint64() && x.Uint64() == uint64(ps[i])) {
+			return true
+		}
+	}
+	return false
+}
+
+// candidate sets c to the i-th candidate of the current window and returns it.
+func (s *sieve) candidate(c *big.Int, i int) *big.Int {
+	c.SetInt64(int64(i) * s.step)
+	return c.Add(c, s.base)
+}
+
+// advance moves the sieve to the next window.
+func (s *sieve) advance() {
+	s.advanceBy(1)
+}
+
+// advanceBy moves the sieve forward by k windows.
+func (s *sieve) advanceBy(k int64) {
+	d := k * sieveWindow * s.step
+	s.base.Add(s.base, big.NewInt(d))
+	s.small = s.base.BitLen() < 32
+	for i, p := range sievePrimes {
+		r := (int64(s.res[i]) + d%int64(p)) % int64(p)
+		if r < 0 {
+			r += int64(p)
+		}
+		s.res[i] = uint32(r)
+	}
+	s.fill()
+}
+
+// fill marks the candidates of the current window that have a small factor.
+func (s *sieve) fill() {
+	for i := range s.comp {
+		s.comp[i] = false
+	}
+	for i, p := range sievePrimes {
+		s.mark(i, p, 0, int64(p))
+		if s.safe {
+			// 2q+1 = 0 (mod p) if q = (p-1)/2 (mod p)
+			s.mark(i, p, (p-1)/2, int64(p-1)/2)
+		}
+	}
+}
+
+// mark marks every candidate of the current window which is congruent to target
+// modulo the i-th sieve prime p, except for the candidate equal to except.
+func (s *sieve) mark(i int, p, target uint32, except int64) {
+	// Solve base + j*step = target (mod p) for j.
+	inv := (uint64(p) + 1) / 2 // inverse of 2
+	if s.step < 0 {
+		inv = uint64(p) - inv
+	}
+	j := (uint64(target) + uint64(p) - uint64(s.res[i])) % uint64(p) * inv % uint64(p)
+
+	for ; j < sieveWindow; j += uint64(p) {
+		if s.small && s.base.Int64()+int64(j)*s.step == except {
+			continue
+		}
+		s.comp[j] = true
+	}
+}
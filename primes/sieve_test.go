@@ -0,0 +1,117 @@
+package primes
+
+import (
+	crand "crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestSieve(t *testing.T) {
+	max := new(big.Int).Lsh(one, 1024)
+	for _, step := range []int64{2, -2} {
+		base, err := crand.Int(crand.Reader, max)
+		if err != nil {
+			t.Fatalf("Failed to generate random base: %v", err)
+		}
+		base.SetBit(base, 0, 1)
+
+		sv := newSieve(base, step)
+		for w := 0; w < 3; w++ {
+			// Compute the residues of the window base independently of the sieve.
+			res := make([]int64, len(sievePrimes))
+			m := new(big.Int)
+			for j, p := range sievePrimes {
+				res[j] = m.Mod(sv.base, m.SetUint64(uint64(p))).Int64()
+			}
+
+			for i, comp := range sv.comp {
+				exp := false
+				for j, p := range sievePrimes {
+					if (res[j]+int64(i)*step)%int64(p) == 0 {
+						exp = true
+						break
+					}
+				}
+				if comp != exp {
+					c := sv.candidate(new(big.Int), i)
+					t.Fatalf("Expected small factor of %s to be %t, got %t", c, exp, comp)
+				}
+			}
+			sv.advance()
+		}
+	}
+}
+
+func TestFindNextSmall(t *testing.T) {
+	for i := int64(0); i < 20000; i += 7 {
+		exp := i
+		for !big.NewInt(exp).ProbablyPrime(0) {
+			exp++
+		}
+		p, err := FindNext(big.NewInt(i), 64)
+		if err != nil {
+			t.Fatalf("Failed to find prime: %v", err)
+		}
+		if p.Int64() != exp {
+			t.Fatalf("Expected FindNext(%d) = %d, got %s", i, exp, p)
+		}
+	}
+}
+
+func TestFindPreviousSmall(t *testing.T) {
+	if _, err := FindPrevious(big.NewInt(1), 64); err != ErrNoPrime {
+		t.Fatalf("Expected ErrNoPrime, got %v", err)
+	}
+
+	for i := int64(2); i < 20000; i += 7 {
+		exp := i
+		for !big.NewInt(exp).ProbablyPrime(0) {
+			exp--
+		}
+		p, err := FindPrevious(big.NewInt(i), 64)
+		if err != nil {
+			t.Fatalf("Failed to find prime: %v", err)
+		}
+		if p.Int64() != exp {
+			t.Fatalf("Expected FindPrevious(%d) = %d, got %s", i, exp, p)
+		}
+	}
+}
+
+func TestFindNextLarge(t *testing.T) {
+	max := new(big.Int).Lsh(one, 256)
+	for i := 0; i < 20; i++ {
+		s, err := crand.Int(crand.Reader, max)
+		if err != nil {
+			t.Fatalf("Failed to generate random start: %v", err)
+		}
+
+		next, err := FindNext(s, 64)
+		if err != nil {
+			t.Fatalf("Failed to find prime: %v", err)
+		}
+		prev, err := FindPrevious(s, 64)
+		if err != nil {
+			t.Fatalf("Failed to find prime: %v", err)
+		}
+		assertNoPrimeBetween(t, prev, next)
+
+		if next.Cmp(s) < 0 || prev.Cmp(s) > 0 {
+			t.Fatalf("Expected %s <= %s <= %s", prev, s, next)
+		}
+	}
+}
+
+// assertNoPrimeBetween checks that a and b are prime and that there is no prime
+// strictly between them.
+func assertNoPrimeBetween(t *testing.T, a, b *big.Int) {
+	t.Helper()
+	if !a.ProbablyPrime(20) || !b.ProbablyPrime(20) {
+		t.Fatalf("Expected %s and %s to be prime", a, b)
+	}
+	for c := new(big.Int).Add(a, one); c.Cmp(b) < 0; c.Add(c, one) {
+		if c.ProbablyPrime(20) {
+			t.Fatalf("Missed prime %s between %s and %s", c, a, b)
+		}
+	}
+}
@@ -0,0 +1,686 @@
+package primes
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"math/bits"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/mmussomele/crypto/rand"
+)
+
+// QuadraticSieve returns a nontrivial factor of the odd composite n with the
+// self-initialising quadratic sieve, sieving on workers goroutines, or GOMAXPROCS if
+// workers is not positive. It is practical for n up to about 200 bits, and falls
+// back to Pollard's rho method and the elliptic curve method below 64 bits. n must
+// be neither prime nor a perfect power, for which the sieve never finds a factor. If
+// ctx is done before a factor is found, it returns the context error.
+func QuadraticSieve(ctx context.Context, n *big.Int, workers int) (*big.Int, error) {
+	if n.Bit(0) == 0 || n.Cmp(three) <= 0 || BailliePSW(n) {
+		panic("crypto/primes: quadratic sieve requires an odd composite")
+	}
+	for _, p := range sievePrimes {
+		if q := big.NewInt(int64(p)); new(big.Int).Mod(n, q).Sign() == 0 {
+			return q, nil
+		}
+	}
+	if _, k := perfectPower(n, 1<<16); k > 1 {
+		panic("crypto/primes: quadratic sieve requires a composite that is not a perfect power")
+	}
+	if n.BitLen() < 64 {
+		// pollardRho is deterministic, so if it fails, retry with random curves.
+		if d := pollardRho(ctx, n, 1<<20); d != nil {
+			return d, nil
+		}
+		for {
+			if d := ecm(ctx, n, 2000, 200000); d != nil {
+				return d, nil
+			}
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	s := newSIQS(n)
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+
+	results := make(chan siqsResult)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.work(ctx, results)
+		}()
+	}
+
+	var full []relation
+	partials := make(map[uint64]relation)
+	for need := len(s.fb) + 32; ; need += 32 {
+		for len(full) < need {
+			var r siqsResult
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case r = <-results:
+			}
+			if r.err != nil {
+				return nil, r.err
+			}
+			for _, rel := range r.rels {
+				if rel.large == nil {
+					full = append(full, rel)
+					continue
+				}
+				l := rel.large.Uint64()
+				if p, ok := partials[l]; ok {
+					if rel.y.Cmp(p.y) != 0 {
+						full = append(full, combine(n, p, rel))
+					}
+					continue
+				}
+				partials[l] = rel
+			}
+		}
+		if d := s.solve(full); d != nil {
+			return d, nil
+		}
+	}
+}
+
+// A relation records that y^2 = -1^e0 * prod(fb[i]^ei) * large^2 (mod n), where the
+// exponents ei count the occurrences of i in exps. For partial relations, large is
+// the single large prime and appears only once.
+type relation struct {
+	y     *big.Int
+	exps  []int
+	large *big.Int
+}
+
+// combine returns the full relation from two partial relations with the same large
+// prime.
+func combine(n *big.Int, r1, r2 relation) relation {
+	y := new(big.Int).Mul(r1.y, r2.y)
+	y.Mod(y, n)
+	exps := make([]int, 0, len(r1.exps)+len(r2.exps))
+	exps = append(append(exps, r1.exps...), r2.exps...)
+	return relation{y: y, exps: exps, large: r1.large}
+}
+
+type siqsResult struct {
+	rels []relation
+	err  error
+}
+
+// siqs holds the parameters of a quadratic sieve of kn, where k is a small
+// multiplier chosen to put many small primes in the factor base.
+type siqs struct {
+	n, kn *big.Int
+
+	// fb holds the factor base primes, with fb[0] = 0 standing for -1. sqrts holds
+	// square roots of kn modulo each prime, and logs their rounded base 2 logarithms.
+	fb    []uint32
+	sqrts []uint32
+	logs  []byte
+
+	// The sieve interval is [-m, m), and candidates are checked if their logarithm
+	// sum is at least thresh. Cofactors up to largeBound give partial relations.
+	m          int
+	thresh     byte
+	largeBound uint64
+
+	// a is a product of numQ factor base primes, approximately target. The first
+	// numQ-1 are chosen at random from fb[qLo:qHi].
+	target         *big.Int
+	numQ, qLo, qHi int
+
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+// siqsParams holds the factor base sizes and sieve half widths by size of n.
+var siqsParams = []struct{ bits, fb, m int }{
+	{64, 100, 8192},
+	{96, 200, 16384},
+	{128, 400, 32768},
+	{150, 800, 32768},
+	{170, 1300, 65536},
+	{190, 2200, 65536},
+	{210, 3500, 98304},
+	{230, 5000, 131072},
+}
+
+const (
+	// minSieved is the smallest prime added to the sieve. The smaller primes are only
+	// used when factoring candidates.
+	minSieved = 30
+
+	// idealQ is the preferred size of the primes whose product is a.
+	idealQ = 2000
+)
+
+func newSIQS(n *big.Int) *siqs {
+	k := multiplier(n)
+	s := &siqs{
+		n:    n,
+		kn:   new(big.Int).Mul(n, big.NewInt(int64(k))),
+		seen: make(map[string]bool),
+	}
+
+	params := siqsParams[len(siqsParams)-1]
+	for _, p := range siqsParams {
+		if n.BitLen() <= p.bits {
+			params = p
+			break
+		}
+	}
+	s.m = params.m
+
+	// The factor base holds the primes p for which kn is a square modulo p, including
+	// those dividing k.
+	s.fb = append(s.fb, 0, 2)
+	s.sqrts = append(s.sqrts, 0, uint32(s.kn.Bit(0)))
+	r, bp := new(big.Int), new(big.Int)
+	for it := NewIterator(3, math.MaxUint32); len(s.fb) < params.fb && it.Next(); {
+		p := it.Prime()
+		bp.SetUint64(p)
+		if jacobi(r.Mod(s.kn, bp), bp) < 0 {
+			continue
+		}
+		s.fb = append(s.fb, uint32(p))
+		s.sqrts = append(s.sqrts, uint32(sqrtModWord(r.Uint64(), p)))
+	}
+	s.logs = make([]byte, len(s.fb))
+	for i, p := range s.fb[1:] {
+		s.logs[i+1] = byte(math.Log2(float64(p)) + 0.5)
+	}
+
+	pmax := uint64(s.fb[len(s.fb)-1])
+	s.largeBound = 32 * pmax
+
+	// |g(x)| is at most about m*sqrt(kn/2). Candidates need all but a large prime and
+	// the unsieved small primes to be accounted for in the sieve.
+	logG := math.Log2(float64(s.m)) + float64(s.kn.BitLen())/2 - 0.5
+	s.thresh = byte(logG - math.Log2(float64(s.largeBound)) - 7)
+
+	// a is about sqrt(2kn)/m.
+	s.target = new(big.Int).Lsh(s.kn, 1)
+	s.target.Sqrt(s.target).Div(s.target, big.NewInt(int64(s.m)))
+	lt := float64(s.target.BitLen())
+	s.numQ = int(lt/math.Log2(idealQ) + 0.5)
+	if s.numQ < 2 {
+		s.numQ = 2
+	}
+	size := math.Exp2(lt / float64(s.numQ))
+	s.qLo = sort.Search(len(s.fb), func(i int) bool { return float64(s.fb[i]) >= size/1.5 })
+	s.qHi = sort.Search(len(s.fb), func(i int) bool { return float64(s.fb[i]) > size*1.5 })
+	for lo := firstSieved(s.fb); s.qHi-s.qLo < 4*s.numQ+8; {
+		if s.qLo > lo {
+			s.qLo--
+		}
+		if s.qHi < len(s.fb) {
+			s.qHi++
+		}
+		if s.qLo == lo && s.qHi == len(s.fb) {
+			break
+		}
+	}
+	return s
+}
+
+// firstSieved returns the index of the first prime in fb that is sieved.
+func firstSieved(fb []uint32) int {
+	return sort.Search(len(fb), func(i int) bool { return fb[i] >= minSieved })
+}
+
+// multipliers are the candidate multipliers for the Knuth-Schroeppel function.
+var multipliers = []uint32{1, 2, 3, 5, 6, 7, 10, 11, 13, 14, 15, 17, 19, 21, 22, 23, 26, 29, 30, 31, 33, 34, 35, 37, 38, 39, 41, 42, 43}
+
+// multiplier returns the small multiplier k maximising the Knuth-Schroeppel function,
+// the expected contribution of small primes to the logarithm of a sieve value
+// of kn.
+func multiplier(n *big.Int) uint32 {
+	best, bestScore := uint32(1), math.Inf(-1)
+	kn, r, bp := new(big.Int), new(big.Int), new(big.Int)
+	for _, k := range multipliers {
+		kn.Mul(n, bp.SetUint64(uint64(k)))
+		score := -0.5 * math.Log(float64(k))
+		switch kn.Bits()[0] & 7 {
+		case 1:
+			score += 2 * math.Ln2
+		case 5:
+			score += math.Ln2
+		case 3, 7:
+			score += 0.5 * math.Ln2
+		}
+		for _, p := range sievePrimes[:300] {
+			lp := math.Log(float64(p))
+			if k%p == 0 {
+				score += lp / float64(p)
+			} else if bp.SetUint64(uint64(p)); jacobi(r.Mod(kn, bp), bp) == 1 {
+				score += 2 * lp / float64(p-1)
+			}
+		}
+		if score > bestScore {
+			best, bestScore = k, score
+		}
+	}
+	return best
+}
+
+// sqrtModWord returns a square root of the quadratic residue a modulo the odd prime
+// p < 2^32 with the Tonelli-Shanks algorithm.
+func sqrtModWord(a, p uint64) uint64 {
+	a %= p
+	if a == 0 {
+		return 0
+	}
+	q, e := p-1, uint(0)
+	for q%2 == 0 {
+		q /= 2
+		e++
+	}
+	z := uint64(2)
+	for powModWord(z, (p-1)/2, p) != p-1 {
+		z++
+	}
+
+	c := powModWord(z, q, p)
+	x := powModWord(a, (q+1)/2, p)
+	t := powModWord(a, q, p)
+	for m := e; t != 1; {
+		i, t2 := uint(0), t
+		for t2 != 1 {
+			t2 = t2 * t2 % p
+			i++
+		}
+		b := c
+		for j := uint(0); j < m-i-1; j++ {
+			b = b * b % p
+		}
+		x = x * b % p
+		c = b * b % p
+		t = t * c % p
+		m = i
+	}
+	return x
+}
+
+// powModWord returns x^e mod p for p < 2^32.
+func powModWord(x, e, p uint64) uint64 {
+	r := uint64(1)
+	x %= p
+	for ; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			r = r * x % p
+		}
+		x = x * x % p
+	}
+	return r
+}
+
+// invModWord returns the inverse of x modulo m, for x coprime to m.
+func invModWord(x, m uint64) uint64 {
+	a, b := int64(x%m), int64(m)
+	u, v := int64(1), int64(0)
+	for b != 0 {
+		q := a / b
+		a, b = b, a-q*b
+		u, v = v, u-q*v
+	}
+	if u < 0 {
+		u += int64(m)
+	}
+	return uint64(u)
+}
+
+// chooseA returns a new value of a, and the indices of its prime factors in the
+// factor base.
+func (s *siqs) chooseA() (*big.Int, []int, error) {
+	for {
+		qs := make([]int, 0, s.numQ)
+		a := big.NewInt(1)
+		for len(qs) < s.numQ-1 {
+			i, err := rand.Uint64n(uint64(s.qHi - s.qLo))
+			if err != nil {
+				return nil, nil, err
+			}
+			if j := s.qLo + int(i); !containsIndex(qs, j) && s.usable(j) {
+				qs = append(qs, j)
+				a.Mul(a, big.NewInt(int64(s.fb[j])))
+			}
+		}
+
+		// The last prime is the one closest to target/a.
+		r, _ := new(big.Float).SetInt(new(big.Int).Div(s.target, a)).Float64()
+		best, bestDist := -1, math.Inf(1)
+		for j := firstSieved(s.fb); j < len(s.fb); j++ {
+			if containsIndex(qs, j) || !s.usable(j) {
+				continue
+			}
+			if d := math.Abs(float64(s.fb[j]) - r); d < bestDist {
+				best, bestDist = j, d
+			}
+		}
+		qs = append(qs, best)
+		a.Mul(a, big.NewInt(int64(s.fb[best])))
+
+		key := a.String()
+		s.mu.Lock()
+		seen := s.seen[key]
+		s.seen[key] = true
+		s.mu.Unlock()
+		if !seen {
+			return a, qs, nil
+		}
+	}
+}
+
+// usable reports whether fb[j] may divide a.
+func (s *siqs) usable(j int) bool {
+	return s.fb[j] >= minSieved && s.sqrts[j] != 0
+}
+
+func containsIndex(xs []int, x int) bool {
+	for _, y := range xs {
+		if y == x {
+			return true
+		}
+	}
+	return false
+}
+
+// work sieves polynomials and sends the relations found until ctx is done.
+func (s *siqs) work(ctx context.Context, results chan<- siqsResult) {
+	send := func(r siqsResult) bool {
+		select {
+		case results <- r:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	nfb := len(s.fb)
+	sieve := make([]byte, 2*s.m)
+	soln1 := make([]int, nfb)
+	soln2 := make([]int, nfb)
+	divA := make([]bool, nfb)
+	bainv := make([][]int, s.numQ)
+	for i := range bainv {
+		bainv[i] = make([]int, nfb)
+	}
+	bs := make([]*big.Int, s.numQ)
+	t, bp := new(big.Int), new(big.Int)
+	m := uint64(s.m)
+
+	for ctx.Err() == nil {
+		a, qs, err := s.chooseA()
+		if err != nil {
+			send(siqsResult{err: err})
+			return
+		}
+		for i := range divA {
+			divA[i] = false
+		}
+		for _, j := range qs {
+			divA[j] = true
+		}
+
+		// B_l = (a/q_l) * (sqrt(kn) * (a/q_l)^-1 mod q_l), so that b = sum(B_l) and
+		// the 2^(numQ-1) values of b with different signs satisfy b^2 = kn (mod a).
+		b := new(big.Int)
+		for l, j := range qs {
+			q := uint64(s.fb[j])
+			aq := new(big.Int).Div(a, bp.SetUint64(q))
+			g := uint64(s.sqrts[j]) * invModWord(t.Mod(aq, bp).Uint64(), q) % q
+			if g > q/2 {
+				g = q - g
+			}
+			bs[l] = aq.Mul(aq, bp.SetUint64(g))
+			b.Add(b, bs[l])
+		}
+
+		for i := 2; i < nfb; i++ {
+			if divA[i] {
+				continue
+			}
+			p := uint64(s.fb[i])
+			ainv := invModWord(t.Mod(a, bp.SetUint64(p)).Uint64(), p)
+			for l := range bs {
+				bainv[l][i] = int(2 * t.Mod(bs[l], bp).Uint64() % p * ainv % p)
+			}
+			bm := t.Mod(b, bp).Uint64()
+			r := uint64(s.sqrts[i])
+			soln1[i] = int((ainv*((r+p-bm)%p) + m) % p)
+			soln2[i] = int((ainv*((2*p-r-bm)%p) + m) % p)
+		}
+
+		var rels []relation
+		for j := 0; j < 1<<uint(s.numQ-1); j++ {
+			if j > 0 {
+				// Flip the sign of B_v, where v is the bit changed in the Gray code.
+				v := bits.TrailingZeros(uint(j))
+				neg := (j^j>>1)>>uint(v)&1 == 1
+				t.Lsh(bs[v], 1)
+				if neg {
+					b.Sub(b, t)
+				} else {
+					b.Add(b, t)
+				}
+				for i := 2; i < nfb; i++ {
+					if divA[i] {
+						continue
+					}
+					p, d := int(s.fb[i]), bainv[v][i]
+					if !neg {
+						d = p - d
+					}
+					if soln1[i] += d; soln1[i] >= p {
+						soln1[i] -= p
+					}
+					if soln2[i] += d; soln2[i] >= p {
+						soln2[i] -= p
+					}
+				}
+			}
+			rels = s.sievePoly(rels, sieve, a, b, qs, divA, soln1, soln2)
+		}
+		if len(rels) > 0 && !send(siqsResult{rels: rels}) {
+			return
+		}
+	}
+}
+
+// sievePoly sieves g(x) = ((ax+b)^2 - kn)/a for x in [-m, m), and appends the
+// relations it finds to rels. soln1 and soln2 hold the positions of the roots of g
+// modulo each prime in the sieve array.
+func (s *siqs) sievePoly(rels []relation, sieve []byte, a, b *big.Int, qs []int, divA []bool, soln1, soln2 []int) []relation {
+	for i := range sieve {
+		sieve[i] = 0
+	}
+	size := len(sieve)
+	for i := firstSieved(s.fb); i < len(s.fb); i++ {
+		if divA[i] {
+			continue
+		}
+		p, lp := int(s.fb[i]), s.logs[i]
+		for x := soln1[i]; x < size; x += p {
+			sieve[x] += lp
+		}
+		if soln2[i] == soln1[i] {
+			continue
+		}
+		for x := soln2[i]; x < size; x += p {
+			sieve[x] += lp
+		}
+	}
+
+	y, g := new(big.Int), new(big.Int)
+	for x, v := range sieve {
+		if v < s.thresh {
+			continue
+		}
+
+		// y = ax+b and g = (y^2-kn)/a
+		y.SetInt64(int64(x - s.m))
+		y.Mul(y, a).Add(y, b)
+		g.Mul(y, y).Sub(g, s.kn).Quo(g, a)
+		if rel, ok := s.factorCandidate(g, x, qs, divA, soln1, soln2); ok {
+			rel.y = new(big.Int).Mod(y, s.n)
+			rels = append(rels, rel)
+		}
+	}
+	return rels
+}
+
+// factorCandidate factors a*g over the factor base, using the sieve roots to find
+// the primes dividing g at sieve position x.
+func (s *siqs) factorCandidate(g *big.Int, x int, qs []int, divA []bool, soln1, soln2 []int) (relation, bool) {
+	var exps []int
+	exps = append(exps, qs...)
+	if g.Sign() < 0 {
+		exps = append(exps, 0)
+		g.Neg(g)
+	}
+	if g.Sign() == 0 {
+		return relation{}, false
+	}
+	for z := g.TrailingZeroBits(); z > 0; z-- {
+		exps = append(exps, 1)
+	}
+	g.Rsh(g, g.TrailingZeroBits())
+
+	bp, r := new(big.Int), new(big.Int)
+	for i := 2; i < len(s.fb); i++ {
+		p := int(s.fb[i])
+		if !divA[i] && x%p != soln1[i] && x%p != soln2[i] {
+			continue
+		}
+		bp.SetInt64(int64(p))
+		for {
+			q, m := new(big.Int).QuoRem(g, bp, r)
+			if m.Sign() != 0 {
+				break
+			}
+			g = q
+			exps = append(exps, i)
+		}
+	}
+
+	switch {
+	case g.Cmp(one) == 0:
+		return relation{exps: exps}, true
+	case g.IsUint64() && g.Uint64() < s.largeBound:
+		return relation{exps: exps, large: g}, true
+	}
+	return relation{}, false
+}
+
+// solve finds dependencies among the relations with Gaussian elimination over GF(2),
+// and returns a nontrivial factor of n from one of them, or nil if all of them are
+// trivial.
+func (s *siqs) solve(rels []relation) *big.Int {
+	cols := len(s.fb)
+	words := (cols + 63) / 64
+	hwords := (len(rels) + 63) / 64
+	rows := make([][]uint64, len(rels))
+	hist := make([][]uint64, len(rels))
+	for i, rel := range rels {
+		rows[i] = make([]uint64, words)
+		hist[i] = make([]uint64, hwords)
+		hist[i][i/64] |= 1 << uint(i%64)
+		for _, e := range rel.exps {
+			rows[i][e/64] ^= 1 << uint(e%64)
+		}
+	}
+
+	used := make([]bool, len(rels))
+	for c := 0; c < cols; c++ {
+		w, bit := c/64, uint64(1)<<uint(c%64)
+		pivot := -1
+		for i := range rows {
+			if !used[i] && rows[i][w]&bit != 0 {
+				pivot = i
+				break
+			}
+		}
+		if pivot < 0 {
+			continue
+		}
+		used[pivot] = true
+		for i := range rows {
+			if i == pivot || rows[i][w]&bit == 0 {
+				continue
+			}
+			xorWords(rows[i], rows[pivot])
+			xorWords(hist[i], hist[pivot])
+		}
+	}
+
+	for i := range rows {
+		if used[i] || !isZero(rows[i]) {
+			continue
+		}
+		if d := s.sqrt(rels, hist[i]); d != nil {
+			return d
+		}
+	}
+	return nil
+}
+
+func xorWords(x, y []uint64) {
+	for i := range x {
+		x[i] ^= y[i]
+	}
+}
+
+func isZero(x []uint64) bool {
+	for _, w := range x {
+		if w != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// sqrt combines the relations selected by dep into X^2 = Y^2 (mod n), and returns
+// gcd(X-Y, n) if it is a nontrivial factor of n.
+func (s *siqs) sqrt(rels []relation, dep []uint64) *big.Int {
+	x, y := big.NewInt(1), big.NewInt(1)
+	counts := make([]int, len(s.fb))
+	for i, rel := range rels {
+		if dep[i/64]>>uint(i%64)&1 == 0 {
+			continue
+		}
+		x.Mul(x, rel.y).Mod(x, s.n)
+		if rel.large != nil {
+			y.Mul(y, rel.large).Mod(y, s.n)
+		}
+		for _, e := range rel.exps {
+			counts[e]++
+		}
+	}
+	bp, e := new(big.Int), new(big.Int)
+	for i := 1; i < len(s.fb); i++ {
+		if counts[i] > 0 {
+			bp.SetUint64(uint64(s.fb[i]))
+			y.Mul(y, bp.Exp(bp, e.SetInt64(int64(counts[i]/2)), s.n)).Mod(y, s.n)
+		}
+	}
+
+	d := x.Sub(x, y).GCD(nil, nil, x.Abs(x), s.n)
+	if d.Cmp(one) == 0 || d.Cmp(s.n) == 0 {
+		return nil
+	}
+	return d
+}
@@ -0,0 +1,120 @@
+package primes
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/mmussomele/crypto/rand"
+)
+
+func TestQuadraticSieve(t *testing.T) {
+	for _, bits := range []int{64, 100, 128, 150, 180} {
+		if bits > 150 && testing.Short() {
+			continue
+		}
+		n := rsaModulus(t, bits)
+		workers := []int{1, 4}
+		if bits > 150 {
+			workers = []int{0}
+		}
+		for _, workers := range workers {
+			d, err := QuadraticSieve(context.Background(), n, workers)
+			if err != nil {
+				t.Fatalf("Failed to factor %s: %v", n, err)
+			}
+			if d.Cmp(one) <= 0 || d.Cmp(n) >= 0 || new(big.Int).Mod(n, d).Sign() != 0 {
+				t.Fatalf("%s is not a nontrivial factor of %s", d, n)
+			}
+		}
+	}
+}
+
+func TestQuadraticSieveCancel(t *testing.T) {
+	n := rsaModulus(t, 220)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := QuadraticSieve(ctx, n, 0); err != context.Canceled {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestQuadraticSieveInvalid(t *testing.T) {
+	p, err := Find(100, 20)
+	if err != nil {
+		t.Fatalf("Failed to find prime: %v", err)
+	}
+	q, err := Find(40, 20)
+	if err != nil {
+		t.Fatalf("Failed to find prime: %v", err)
+	}
+	for _, n := range []*big.Int{
+		big.NewInt(65521),
+		p,
+		new(big.Int).Mul(p, p),
+		new(big.Int).Exp(q, big.NewInt(3), nil),
+		new(big.Int).Exp(new(big.Int).Mul(p, q), big.NewInt(2), nil),
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("Expected a panic for %s", n)
+				}
+			}()
+			QuadraticSieve(context.Background(), n, 1)
+		}()
+	}
+}
+
+func TestFactorSieve(t *testing.T) {
+	n := rsaModulus(t, 140)
+	fs := assertFactors(t, n, &FactorOptions{RhoIterations: -1, PM1Bound: -1, ECMCurves: -1})
+	if len(fs) != 2 {
+		t.Fatalf("Expected two factors of %s, got %v", n, fs)
+	}
+}
+
+func TestSqrtModWord(t *testing.T) {
+	for _, p := range append([]uint32{3, 5, 17, 65537, 4294967291}, sievePrimes[:500]...) {
+		q := uint64(p)
+		for _, x := range []uint64{1, 2, 3, q / 2, q - 1, 1234567 % q} {
+			a := x * x % q
+			r := sqrtModWord(a, q)
+			if r*r%q != a {
+				t.Fatalf("sqrtModWord(%d, %d) = %d", a, q, r)
+			}
+		}
+	}
+}
+
+func BenchmarkQuadraticSieve150(b *testing.B) {
+	n := rsaModulus(b, 150)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		QuadraticSieve(context.Background(), n, 0)
+	}
+}
+
+// rsaModulus returns a modulus of exactly bits bits generated like rsa.NewKey, which
+// can not be imported here.
+func rsaModulus(tb testing.TB, bits int) *big.Int {
+	for {
+		p, err := Find(bits/2+1, 20)
+		if err != nil {
+			tb.Fatalf("Failed to find prime: %v", err)
+		}
+		qMin := new(big.Int).Lsh(one, uint(bits-1))
+		qMin.Div(qMin, p)
+		qn, err := rand.Int(qMin)
+		if err != nil {
+			tb.Fatalf("Failed to read random number: %v", err)
+		}
+		q, err := FindNext(qn.Add(qn, qMin), 20)
+		if err != nil {
+			tb.Fatalf("Failed to find prime: %v", err)
+		}
+		if n := new(big.Int).Mul(p, q); n.BitLen() == bits {
+			return n
+		}
+	}
+}
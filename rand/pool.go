@@ -0,0 +1,245 @@
+package rand
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	// SeedBits is the number of bits of entropy that must be credited to a Pool
+	// before it produces any output.
+	SeedBits = 256
+
+	// SeedFileSize is the size of the seed files written by SaveSeedFile.
+	SeedFileSize = 512
+
+	// kernelRead is the number of bytes mixed in from the kernel on every read.
+	kernelRead = 32
+)
+
+// ErrNotSeeded is returned when reading from a Pool that has not been credited with
+// SeedBits bits of entropy.
+var ErrNotSeeded = errors.New("crypto/rand: entropy pool not seeded")
+
+// Domain separation labels for the pool hash.
+const (
+	labelInput  = 'i'
+	labelOutput = 'o'
+	labelNext   = 'n'
+)
+
+// Pool is an entropy pool. It mixes input from the kernel, seed files and callers
+// through SHA-256 and generates output from the pool state with SHA-256 in counter
+// mode. The state is replaced by a hash of itself after every read, so earlier
+// output cannot be recovered from a compromised state.
+//
+// A Pool never produces output before SeedBits bits of entropy have been credited
+// to it.
+type Pool struct {
+	mu sync.Mutex
+
+	kernel io.Reader
+	state  [sha256.Size]byte
+	credit int
+	seeded bool
+
+	h    hash.Hash
+	kbuf [kernelRead]byte
+}
+
+// NewPool returns a new entropy pool drawing from kernel, which may be nil. Input
+// read from kernel is fully credited, so kernel must be a cryptographically secure
+// source such as /dev/urandom.
+func NewPool(kernel io.Reader) *Pool {
+	return &Pool{kernel: kernel}
+}
+
+// AddEntropy mixes b into the pool and credits it with the given number of bits of
+// entropy, which is capped at 8*len(b). Noise of unknown quality should be added
+// with 0 bits.
+func (p *Pool) AddEntropy(b []byte, bits int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.add(b, bits)
+}
+
+// Seeded reports whether the pool has been credited with at least SeedBits bits of
+// entropy.
+func (p *Pool) Seeded() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.seeded
+}
+
+// Read fills b with random bytes. If the pool has a kernel source, fresh input from
+// it is mixed in first. Read returns ErrNotSeeded if the pool has not been seeded.
+func (p *Pool) Read(b []byte) (n int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Once seeded, the pool remains secure even if the kernel becomes unavailable.
+	if err := p.addKernel(); err != nil && !p.seeded {
+		return 0, err
+	}
+	if !p.seeded {
+		return 0, ErrNotSeeded
+	}
+
+	// Each block hashes the state and a labelled counter, which fits in a single
+	// SHA-256 block.
+	var in [sha256.Size + 9]byte
+	copy(in[:], p.state[:])
+	in[sha256.Size] = labelOutput
+	for i := uint64(0); n < len(b); i++ {
+		binary.BigEndian.PutUint64(in[sha256.Size+1:], i)
+		out := sha256.Sum256(in[:])
+		n += copy(b[n:], out[:])
+	}
+
+	in[sha256.Size] = labelNext
+	p.state = sha256.Sum256(in[:sha256.Size+1])
+	return n, nil
+}
+
+// LoadSeedFile mixes the contents of the seed file at path into the pool. The seed
+// is credited only if credit is set, which should only be done if the file is known
+// to have been written by SaveSeedFile and not to have been copied to other devices.
+//
+// If the pool is seeded afterwards, by the seed or by input from its kernel source,
+// the file is immediately replaced by a new seed so that the same seed is never
+// loaded twice.
+func (p *Pool) LoadSeedFile(path string, credit bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	b, err := io.ReadAll(io.LimitReader(f, SeedFileSize))
+	if err != nil {
+		return err
+	}
+
+	bits := 0
+	if credit {
+		bits = 8 * len(b)
+	}
+	p.mu.Lock()
+	p.add(b, bits)
+	p.addKernel()
+	seeded := p.seeded
+	p.mu.Unlock()
+
+	if !seeded {
+		return nil
+	}
+	return p.SaveSeedFile(path)
+}
+
+// SaveSeedFile atomically replaces the file at path with SeedFileSize bytes of
+// output from the pool. The new file is written in the same directory and renamed
+// over path once it has been synced to disk. The pool must be seeded.
+func (p *Pool) SaveSeedFile(path string) (err error) {
+	seed := make([]byte, SeedFileSize)
+	if _, err := io.ReadFull(p, seed); err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if err = f.Chmod(0600); err != nil {
+		return err
+	}
+	if _, err = f.Write(seed); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+
+	// Sync the directory so that the rename survives a crash.
+	if d, derr := os.Open(dir); derr == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// addKernel mixes fresh input from the kernel source into the pool, if it has one.
+func (p *Pool) addKernel() error {
+	if p.kernel == nil {
+		return nil
+	}
+	if _, err := io.ReadFull(p.kernel, p.kbuf[:]); err != nil {
+		return err
+	}
+	p.add(p.kbuf[:], 8*len(p.kbuf))
+	p.kbuf = [kernelRead]byte{}
+	return nil
+}
+
+func (p *Pool) add(b []byte, bits int) {
+	var hdr [9]byte
+	hdr[0] = labelInput
+	binary.BigEndian.PutUint64(hdr[1:], uint64(len(b)))
+
+	if p.h == nil {
+		p.h = sha256.New()
+	}
+	h := p.h
+	h.Reset()
+	h.Write(p.state[:])
+	h.Write(hdr[:])
+	h.Write(b)
+	h.Sum(p.state[:0])
+
+	if max := 8 * len(b); bits > max {
+		bits = max
+	}
+	if bits > 0 {
+		p.credit += bits
+	}
+	if p.credit >= SeedBits {
+		p.seeded = true
+	}
+}
+
+// AddEntropy mixes b into the default pool used by Read and credits it with the
+// given number of bits of entropy. See Pool.AddEntropy.
+func AddEntropy(b []byte, bits int) {
+	r.AddEntropy(b, bits)
+}
+
+// LoadSeedFile mixes the seed file at path into the default pool used by Read. See
+// Pool.LoadSeedFile.
+func LoadSeedFile(path string, credit bool) error {
+	return r.LoadSeedFile(path, credit)
+}
+
+// SaveSeedFile writes a new seed file from the default pool used by Read. See
+// Pool.SaveSeedFile.
+func SaveSeedFile(path string) error {
+	return r.SaveSeedFile(path)
+}
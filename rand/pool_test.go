@@ -0,0 +1,185 @@
+package rand
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mmussomele/crypto/internal/sts"
+)
+
+func TestPoolNotSeeded(t *testing.T) {
+	p := NewPool(nil)
+	b := make([]byte, 16)
+	if _, err := p.Read(b); err != ErrNotSeeded {
+		t.Fatalf("Expected ErrNotSeeded, got %v", err)
+	}
+
+	// Credits are capped at the size of the input.
+	p.AddEntropy(make([]byte, 8), SeedBits)
+	p.AddEntropy(make([]byte, 8), 0)
+	if _, err := p.Read(b); err != ErrNotSeeded {
+		t.Fatalf("Expected ErrNotSeeded, got %v", err)
+	}
+	if !bytes.Equal(b, make([]byte, 16)) {
+		t.Fatal("Unseeded pool produced output")
+	}
+
+	p.AddEntropy(make([]byte, SeedBits/8), SeedBits-64)
+	if !p.Seeded() {
+		t.Fatal("Expected pool to be seeded")
+	}
+	if _, err := p.Read(b); err != nil {
+		t.Fatalf("Failed to read from seeded pool: %v", err)
+	}
+}
+
+func TestPoolOutput(t *testing.T) {
+	p := seededPool(t)
+	a := make([]byte, 100)
+	b := make([]byte, 100)
+	if _, err := io.ReadFull(p, a); err != nil {
+		t.Fatalf("Failed to read from pool: %v", err)
+	}
+	if _, err := io.ReadFull(p, b); err != nil {
+		t.Fatalf("Failed to read from pool: %v", err)
+	}
+	if bytes.Equal(a, b) {
+		t.Fatal("Consecutive reads returned the same output")
+	}
+
+	e, err := sts.ReadBits(p, stsBits)
+	if err != nil {
+		t.Fatalf("Failed to read random bits: %v", err)
+	}
+	assertSuite(t, e)
+}
+
+func TestPoolKernel(t *testing.T) {
+	k := &flakyReader{src: new(reader), fail: true}
+	p := NewPool(k)
+	if _, err := p.Read(make([]byte, 16)); err != errFlaky {
+		t.Fatalf("Expected kernel error, got %v", err)
+	}
+
+	k.fail = false
+	if _, err := p.Read(make([]byte, 16)); err != nil {
+		t.Fatalf("Failed to read from pool: %v", err)
+	}
+
+	// A seeded pool does not depend on the kernel anymore.
+	k.fail = true
+	if _, err := p.Read(make([]byte, 16)); err != nil {
+		t.Fatalf("Failed to read from pool: %v", err)
+	}
+}
+
+func TestSeedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "random-seed")
+	if err := NewPool(nil).SaveSeedFile(path); err != ErrNotSeeded {
+		t.Fatalf("Expected ErrNotSeeded, got %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Unseeded pool wrote seed file: %v", err)
+	}
+
+	if err := seededPool(t).SaveSeedFile(path); err != nil {
+		t.Fatalf("Failed to save seed file: %v", err)
+	}
+	seed := readSeed(t, path)
+	if len(seed) != SeedFileSize {
+		t.Fatalf("Expected %d byte seed file, got %d", SeedFileSize, len(seed))
+	}
+
+	// An uncredited seed does not seed the pool and leaves the file in place.
+	p := NewPool(nil)
+	if err := p.LoadSeedFile(path, false); err != nil {
+		t.Fatalf("Failed to load seed file: %v", err)
+	}
+	if p.Seeded() {
+		t.Fatal("Uncredited seed file seeded the pool")
+	}
+	if !bytes.Equal(seed, readSeed(t, path)) {
+		t.Fatal("Seed file changed")
+	}
+
+	// A credited seed seeds the pool and is replaced.
+	p = NewPool(nil)
+	if err := p.LoadSeedFile(path, true); err != nil {
+		t.Fatalf("Failed to load seed file: %v", err)
+	}
+	if !p.Seeded() {
+		t.Fatal("Credited seed file did not seed the pool")
+	}
+	if bytes.Equal(seed, readSeed(t, path)) {
+		t.Fatal("Seed file was not refreshed")
+	}
+
+	// A pool with a kernel source, like the default pool, is seeded by the kernel
+	// and replaces even an uncredited seed.
+	seed = readSeed(t, path)
+	k := &flakyReader{src: new(reader), fail: true}
+	p = NewPool(k)
+	if err := p.LoadSeedFile(path, false); err != nil {
+		t.Fatalf("Failed to load seed file: %v", err)
+	}
+	if !bytes.Equal(seed, readSeed(t, path)) {
+		t.Fatal("Seed file changed without a kernel source")
+	}
+	k.fail = false
+	if err := p.LoadSeedFile(path, false); err != nil {
+		t.Fatalf("Failed to load seed file: %v", err)
+	}
+	if bytes.Equal(seed, readSeed(t, path)) {
+		t.Fatal("Seed file was not refreshed")
+	}
+
+	matches, err := filepath.Glob(filepath.Join(filepath.Dir(path), ".*"))
+	if err != nil || len(matches) != 0 {
+		t.Fatalf("Temporary files left behind: %v %v", matches, err)
+	}
+}
+
+var errFlaky = errors.New("flaky reader failure")
+
+type flakyReader struct {
+	src  io.Reader
+	fail bool
+}
+
+func (f *flakyReader) Read(b []byte) (int, error) {
+	if f.fail {
+		return 0, errFlaky
+	}
+	return f.src.Read(b)
+}
+
+func seededPool(t *testing.T) *Pool {
+	t.Helper()
+	b := make([]byte, SeedBits/8)
+	if _, err := Reader().Read(b); err != nil {
+		t.Fatalf("Failed to read seed: %v", err)
+	}
+	p := NewPool(nil)
+	p.AddEntropy(b, SeedBits)
+	return p
+}
+
+func readSeed(t *testing.T, path string) []byte {
+	t.Helper()
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat seed file: %v", err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0600 {
+		t.Fatalf("Expected seed file mode 0600, got %o", perm)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read seed file: %v", err)
+	}
+	return b
+}
@@ -27,9 +27,10 @@ func (r *reader) Read(b []byte) (n int, err error) {
 	return io.ReadFull(r.src, b)
 }
 
-var r = new(reader)
+// r is the default pool, seeded by the kernel.
+var r = NewPool(new(reader))
 
-// Read fills b with random bytes.
+// Read fills b with random bytes from the default entropy pool.
 func Read(b []byte) (n int, err error) {
 	return io.ReadFull(r, b)
 }
@@ -39,27 +40,68 @@ func Reader() io.Reader {
 	return new(reader)
 }
 
-var one = big.NewInt(1)
+// minRead is the minimum number of bytes read at once when sampling integers. Short
+// candidates are sampled in batches to amortize the cost of the read.
+const minRead = 64
 
+var bufs = sync.Pool{
+	New: func() interface{} { return new([]byte) },
+}
+
+// Int returns a uniform random value in [0, max). It panics if max <= 0.
 func Int(max *big.Int) (*big.Int, error) {
-	n := new(big.Int).Sub(max, one).BitLen()
-	buf := make([]byte, (n+7)/8)
+	return IntInto(new(big.Int), max)
+}
+
+// IntInto sets z to a uniform random value in [0, max) and returns z. z may be max.
+// It panics if max <= 0. If an error is returned, the value of z is undefined.
+func IntInto(z, max *big.Int) (*big.Int, error) {
+	if max.Sign() <= 0 {
+		panic("crypto/rand: argument to Int is <= 0")
+	}
+
+	// n is the bit length of max-1. Candidates are n-bit random numbers, which
+	// are accepted with probability at least 1/2.
+	n := max.BitLen()
+	if max.TrailingZeroBits() == uint(n-1) {
+		n--
+	}
+	if n == 0 {
+		return z.SetInt64(0), nil
+	}
+	if z == max {
+		max = new(big.Int).Set(max)
+	}
+
+	k := (n + 7) / 8
+	mask := byte(0xff)
+	if r := n % 8; r != 0 {
+		mask = byte(1<<uint(r)) - 1
+	}
+
+	bp := bufs.Get().(*[]byte)
+	size := k * (1 + minRead/k)
+	if cap(*bp) < size {
+		*bp = make([]byte, size)
+	}
+	buf := (*bp)[:size]
+	defer func() {
+		for i := range buf {
+			buf[i] = 0
+		}
+		bufs.Put(bp)
+	}()
 
-	candidate := new(big.Int)
 	for {
 		if _, err := Read(buf); err != nil {
 			return nil, err
 		}
-		candidate.SetBytes(buf)
-
-		// If the candidate has more bits than the allowed max, clear them.
-		c := candidate.BitLen()
-		for i := n; i < c; i++ {
-			candidate.SetBit(candidate, i, 0)
-		}
-
-		if candidate.Cmp(max) < 0 {
-			return candidate, nil
+		for c := buf; len(c) > 0; c = c[k:] {
+			c[0] &= mask
+			z.SetBytes(c[:k])
+			if z.Cmp(max) < 0 {
+				return z, nil
+			}
 		}
 	}
 }
@@ -0,0 +1,186 @@
+package rand
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/mmussomele/crypto/internal/sts"
+)
+
+const stsBits = 1 << 20
+
+// minP is the P-value below which a statistical test fails. It is far smaller than
+// sts.Alpha so that running the whole suite on a good source rarely fails.
+const minP = 1e-5
+
+func TestReaderStatistics(t *testing.T) {
+	e, err := sts.ReadBits(Reader(), stsBits)
+	if err != nil {
+		t.Fatalf("Failed to read random bits: %v", err)
+	}
+	assertSuite(t, e)
+}
+
+func TestReadStatistics(t *testing.T) {
+	e, err := sts.ReadBits(readerFunc(Read), stsBits)
+	if err != nil {
+		t.Fatalf("Failed to read random bits: %v", err)
+	}
+	assertSuite(t, e)
+}
+
+func TestIntStatistics(t *testing.T) {
+	// Bounds that are not byte aligned exercise the masking of the top bits.
+	for _, k := range []uint{8, 13, 61} {
+		max := new(big.Int).Lsh(big.NewInt(1), k)
+		e := make([]byte, 0, stsBits+int(k))
+		for len(e) < stsBits {
+			v, err := Int(max)
+			if err != nil {
+				t.Fatalf("Failed to generate random int: %v", err)
+			}
+			for i := int(k) - 1; i >= 0; i-- {
+				e = append(e, byte(v.Bit(i)))
+			}
+		}
+		assertSuite(t, e[:stsBits])
+	}
+}
+
+func TestIntUniform(t *testing.T) {
+	for _, m := range []int64{1, 2, 3, 10, 255, 256, 257, 1000} {
+		max := big.NewInt(m)
+		counts := make([]int, m)
+		for i := 0; i < samples; i++ {
+			v, err := Int(max)
+			if err != nil {
+				t.Fatalf("Failed to generate random int: %v", err)
+			}
+			if v.Sign() < 0 || v.Cmp(max) >= 0 {
+				t.Fatalf("Expected value in [0, %d), got %d", m, v)
+			}
+			counts[v.Int64()]++
+		}
+		assertUniform(t, counts)
+	}
+}
+
+func TestIntUniformLarge(t *testing.T) {
+	// Bounds just above a power of two reject almost half of all candidates. Count
+	// the values in 100 buckets of equal size.
+	const buckets = 100
+	for _, b := range []uint{256, 1021, 4096} {
+		max := new(big.Int).Lsh(big.NewInt(1), b)
+		max.Add(max, big.NewInt(buckets))
+		max.Sub(max, new(big.Int).Mod(max, big.NewInt(buckets)))
+		width := new(big.Int).Div(max, big.NewInt(buckets))
+
+		counts := make([]int, buckets)
+		v := new(big.Int)
+		for i := 0; i < samples/10; i++ {
+			if _, err := IntInto(v, max); err != nil {
+				t.Fatalf("Failed to generate random int: %v", err)
+			}
+			if v.Sign() < 0 || v.Cmp(max) >= 0 {
+				t.Fatalf("Expected value in [0, %d), got %d", max, v)
+			}
+			counts[new(big.Int).Div(v, width).Int64()]++
+		}
+		assertUniform(t, counts)
+	}
+}
+
+func TestIntIntoAliased(t *testing.T) {
+	for _, m := range []int64{1, 3, 1000, 1 << 40} {
+		z := big.NewInt(m)
+		if v, err := IntInto(z, z); err != nil || v != z || z.Sign() < 0 || z.Int64() >= m {
+			t.Fatalf("Expected a value in [0, %d), got %d (%v)", m, z, err)
+		}
+	}
+}
+
+func TestIntInvalid(t *testing.T) {
+	for _, m := range []int64{0, -1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("Expected Int(%d) to panic", m)
+				}
+			}()
+			Int(big.NewInt(m))
+		}()
+	}
+}
+
+func BenchmarkInt256(b *testing.B) {
+	benchmarkInt(256, b)
+}
+
+func BenchmarkInt512(b *testing.B) {
+	benchmarkInt(512, b)
+}
+
+func BenchmarkInt1024(b *testing.B) {
+	benchmarkInt(1024, b)
+}
+
+func BenchmarkInt2048(b *testing.B) {
+	benchmarkInt(2048, b)
+}
+
+func BenchmarkInt4096(b *testing.B) {
+	benchmarkInt(4096, b)
+}
+
+func BenchmarkInt8192(b *testing.B) {
+	benchmarkInt(8192, b)
+}
+
+func BenchmarkIntInto256(b *testing.B) {
+	benchmarkIntInto(256, b)
+}
+
+func BenchmarkIntInto8192(b *testing.B) {
+	benchmarkIntInto(8192, b)
+}
+
+// benchMax returns 3*2^(bits-2), which rejects a quarter of all candidates.
+func benchMax(bits uint) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(3), bits-2)
+}
+
+func benchmarkInt(bits uint, b *testing.B) {
+	max := benchMax(bits)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Int(max)
+	}
+}
+
+func benchmarkIntInto(bits uint, b *testing.B) {
+	max := benchMax(bits)
+	z := new(big.Int)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		IntInto(z, max)
+	}
+}
+
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(b []byte) (int, error) {
+	return f(b)
+}
+
+func assertSuite(t *testing.T, e []byte) {
+	t.Helper()
+	results, err := sts.Suite(e)
+	if err != nil {
+		t.Fatalf("Failed to run statistical tests: %v", err)
+	}
+	for _, r := range results {
+		if r.P < minP {
+			t.Fatalf("%s test failed with P = %f", r.Name, r.P)
+		}
+	}
+}
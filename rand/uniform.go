@@ -0,0 +1,65 @@
+package rand
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"math/bits"
+)
+
+// Uint64n returns a uniform random value in [0, n). It panics if n is 0.
+//
+// Values are drawn by rejection sampling, so the result has no modulo bias.
+func Uint64n(n uint64) (uint64, error) {
+	if n == 0 {
+		panic("crypto/rand: argument to Uint64n is 0")
+	}
+
+	mask := uint64(1)<<uint(bits.Len64(n-1)) - 1
+	buf := make([]byte, 8)
+	for {
+		if _, err := Read(buf); err != nil {
+			return 0, err
+		}
+		if v := binary.BigEndian.Uint64(buf) & mask; v < n {
+			return v, nil
+		}
+	}
+}
+
+// Range returns a uniform random value in [lo, hi). It panics if hi <= lo.
+func Range(lo, hi uint64) (uint64, error) {
+	if hi <= lo {
+		panic("crypto/rand: empty range")
+	}
+	v, err := Uint64n(hi - lo)
+	if err != nil {
+		return 0, err
+	}
+	return lo + v, nil
+}
+
+// Shuffle randomly permutes n elements using the Fisher-Yates algorithm. swap swaps
+// the elements with indexes i and j. Every permutation is equally likely.
+func Shuffle(n int, swap func(i, j int)) error {
+	if n < 0 {
+		panic("crypto/rand: negative argument to Shuffle")
+	}
+	for i := n - 1; i > 0; i-- {
+		j, err := Uint64n(uint64(i + 1))
+		if err != nil {
+			return err
+		}
+		swap(i, int(j))
+	}
+	return nil
+}
+
+// Token returns n random bytes encoded with the unpadded URL-safe base64 alphabet of
+// RFC 4648.
+func Token(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
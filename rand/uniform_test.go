@@ -0,0 +1,136 @@
+package rand
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/mmussomele/crypto/internal/sts"
+)
+
+const samples = 100000
+
+func TestUint64n(t *testing.T) {
+	for _, n := range []uint64{1, 2, 3, 10, 100, 1000} {
+		counts := make([]int, n)
+		for i := 0; i < samples; i++ {
+			v, err := Uint64n(n)
+			if err != nil {
+				t.Fatalf("Failed to generate random value: %v", err)
+			}
+			if v >= n {
+				t.Fatalf("Expected value in [0, %d), got %d", n, v)
+			}
+			counts[v]++
+		}
+		assertUniform(t, counts)
+	}
+}
+
+func TestUint64nLarge(t *testing.T) {
+	// n = 3*2^62 rejects a quarter of all candidates and would be heavily biased by
+	// a modulo reduction. Count the values in 24 buckets of equal size.
+	const n = 3 << 62
+	counts := make([]int, 24)
+	for i := 0; i < samples; i++ {
+		v, err := Uint64n(n)
+		if err != nil {
+			t.Fatalf("Failed to generate random value: %v", err)
+		}
+		if v >= n {
+			t.Fatalf("Expected value in [0, %d), got %d", uint64(n), v)
+		}
+		counts[v/(n/24)]++
+	}
+	assertUniform(t, counts)
+}
+
+func TestRange(t *testing.T) {
+	const lo, hi = 1<<40 - 17, 1<<40 + 20
+	counts := make([]int, hi-lo)
+	for i := 0; i < samples; i++ {
+		v, err := Range(lo, hi)
+		if err != nil {
+			t.Fatalf("Failed to generate random value: %v", err)
+		}
+		if v < lo || v >= hi {
+			t.Fatalf("Expected value in [%d, %d), got %d", uint64(lo), uint64(hi), v)
+		}
+		counts[v-lo]++
+	}
+	assertUniform(t, counts)
+}
+
+func TestShuffle(t *testing.T) {
+	// Count how often each of the 24 permutations of 4 elements occurs.
+	counts := make([]int, 24)
+	for i := 0; i < samples; i++ {
+		p := []int{0, 1, 2, 3}
+		err := Shuffle(len(p), func(i, j int) { p[i], p[j] = p[j], p[i] })
+		if err != nil {
+			t.Fatalf("Failed to shuffle: %v", err)
+		}
+		counts[permutationIndex(p)]++
+	}
+	assertUniform(t, counts)
+}
+
+func TestToken(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 3, 16, 32, 33} {
+		tok, err := Token(n)
+		if err != nil {
+			t.Fatalf("Failed to generate token: %v", err)
+		}
+		b, err := base64.RawURLEncoding.DecodeString(tok)
+		switch {
+		case err != nil:
+			t.Fatalf("Token %q is not URL-safe base64: %v", tok, err)
+		case len(b) != n:
+			t.Fatalf("Expected %d bytes, got %d", n, len(b))
+		}
+	}
+}
+
+func BenchmarkUint64n(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Uint64n(1000)
+	}
+}
+
+// permutationIndex returns the lexicographic rank of a permutation of 0..len(p)-1.
+func permutationIndex(p []int) int {
+	var idx int
+	for i := range p {
+		var smaller int
+		for _, q := range p[i+1:] {
+			if q < p[i] {
+				smaller++
+			}
+		}
+		idx = idx*(len(p)-i) + smaller
+	}
+	return idx
+}
+
+// assertUniform performs a chi-squared goodness of fit test of counts against the
+// uniform distribution.
+func assertUniform(t *testing.T, counts []int) {
+	t.Helper()
+	if len(counts) < 2 {
+		return
+	}
+
+	var total int
+	for _, c := range counts {
+		total += c
+	}
+	exp := float64(total) / float64(len(counts))
+
+	var chi2 float64
+	for _, c := range counts {
+		d := float64(c) - exp
+		chi2 += d * d / exp
+	}
+	if p := sts.Igamc(float64(len(counts)-1)/2, chi2/2); p < minP {
+		t.Fatalf("Distribution is not uniform: chi2 = %f, P = %f", chi2, p)
+	}
+}
@@ -0,0 +1,62 @@
+package rand
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"time"
+)
+
+// A UUID is an RFC 9562 universally unique identifier.
+type UUID [16]byte
+
+// String returns the canonical hyphenated hexadecimal form of u.
+func (u UUID) String() string {
+	var b [36]byte
+	hex.Encode(b[0:8], u[0:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], u[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], u[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], u[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:], u[10:])
+	return string(b[:])
+}
+
+// Version returns the version field of u.
+func (u UUID) Version() int {
+	return int(u[6] >> 4)
+}
+
+// UUIDv4 returns a random version 4 UUID.
+func UUIDv4() (UUID, error) {
+	var u UUID
+	if _, err := Read(u[:]); err != nil {
+		return UUID{}, err
+	}
+	u.setVersion(4)
+	return u, nil
+}
+
+// UUIDv7 returns a version 7 UUID, which starts with the current Unix time in
+// milliseconds followed by 74 random bits. UUIDs created in different milliseconds
+// sort in creation order.
+func UUIDv7() (UUID, error) {
+	var u UUID
+	if _, err := Read(u[6:]); err != nil {
+		return UUID{}, err
+	}
+
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(time.Now().UnixNano()/int64(time.Millisecond)))
+	copy(u[:6], ts[2:])
+	u.setVersion(7)
+	return u, nil
+}
+
+// setVersion sets the version field and the RFC 9562 variant bits of u.
+func (u *UUID) setVersion(v byte) {
+	u[6] = u[6]&0x0f | v<<4
+	u[8] = u[8]&0x3f | 0x80
+}
@@ -0,0 +1,71 @@
+package rand
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+	"time"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+func TestUUIDv4(t *testing.T) {
+	seen := make(map[UUID]bool)
+	for i := 0; i < 1000; i++ {
+		u, err := UUIDv4()
+		if err != nil {
+			t.Fatalf("Failed to generate UUID: %v", err)
+		}
+		assertUUID(t, u, 4)
+		if seen[u] {
+			t.Fatalf("Duplicate UUID %s", u)
+		}
+		seen[u] = true
+	}
+}
+
+func TestUUIDv7(t *testing.T) {
+	before := time.Now().UnixNano() / int64(time.Millisecond)
+	u, err := UUIDv7()
+	if err != nil {
+		t.Fatalf("Failed to generate UUID: %v", err)
+	}
+	after := time.Now().UnixNano() / int64(time.Millisecond)
+	assertUUID(t, u, 7)
+
+	var ts int64
+	for _, b := range u[:6] {
+		ts = ts<<8 | int64(b)
+	}
+	if ts < before || ts > after {
+		t.Fatalf("Expected timestamp in [%d, %d], got %d", before, after, ts)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	v, err := UUIDv7()
+	if err != nil {
+		t.Fatalf("Failed to generate UUID: %v", err)
+	}
+	if bytes.Compare(u[:], v[:]) >= 0 {
+		t.Fatalf("Expected %s < %s", u, v)
+	}
+}
+
+func TestUUIDString(t *testing.T) {
+	u := UUID{0xf8, 0x1d, 0x4f, 0xae, 0x7d, 0xec, 0x11, 0xd0, 0xa7, 0x65, 0x00, 0xa0, 0xc9, 0x1e, 0x6b, 0xf6}
+	if s := u.String(); s != "f81d4fae-7dec-11d0-a765-00a0c91e6bf6" {
+		t.Fatalf("Unexpected string %s", s)
+	}
+}
+
+func assertUUID(t *testing.T, u UUID, version int) {
+	t.Helper()
+	switch {
+	case u.Version() != version:
+		t.Fatalf("Expected version %d, got %d", version, u.Version())
+	case u[8]&0xc0 != 0x80:
+		t.Fatalf("Expected RFC 9562 variant, got %#x", u[8])
+	case !uuidPattern.MatchString(u.String()):
+		t.Fatalf("Malformed UUID string %s", u)
+	}
+}
@@ -0,0 +1,321 @@
+package rsa
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/subtle"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+)
+
+// ErrRecipientInfo is returned when a KEMRecipientInfo is malformed or uses
+// unsupported algorithms.
+var ErrRecipientInfo = errors.New("crypto/rsa: malformed or unsupported KEMRecipientInfo")
+
+var (
+	oidKEMRecipientInfo = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 13, 3}
+	oidRSAKEM           = asn1.ObjectIdentifier{1, 0, 18033, 2, 2, 4}
+	oidKDF2             = asn1.ObjectIdentifier{1, 3, 133, 16, 840, 9, 44, 1, 1}
+	oidHKDFSHA256       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 3, 28}
+	oidHKDFSHA384       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 3, 29}
+	oidHKDFSHA512       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 3, 30}
+	oidAES128Wrap       = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 5}
+	oidAES192Wrap       = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 25}
+	oidAES256Wrap       = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 45}
+)
+
+var hashOIDs = map[crypto.Hash]asn1.ObjectIdentifier{
+	crypto.SHA1:   {1, 3, 14, 3, 2, 26},
+	crypto.SHA224: {2, 16, 840, 1, 101, 3, 4, 2, 4},
+	crypto.SHA256: {2, 16, 840, 1, 101, 3, 4, 2, 1},
+	crypto.SHA384: {2, 16, 840, 1, 101, 3, 4, 2, 2},
+	crypto.SHA512: {2, 16, 840, 1, 101, 3, 4, 2, 3},
+}
+
+var hkdfOIDs = map[crypto.Hash]asn1.ObjectIdentifier{
+	crypto.SHA256: oidHKDFSHA256,
+	crypto.SHA384: oidHKDFSHA384,
+	crypto.SHA512: oidHKDFSHA512,
+}
+
+// KEMRecipientInfo is a CMS KEMRecipientInfo, as specified by RFC 9629, for RSA-KEM.
+// It carries a content-encryption key for a recipient identified by its subject key
+// identifier, wrapped with AES Key Wrap under a key encapsulated with RSA-KEM.
+type KEMRecipientInfo struct {
+	// KeyID is the subject key identifier of the recipient.
+	KeyID []byte
+
+	// KDF derives the key-encryption key from the encapsulated secret. It must be
+	// KDF2 or HKDF to be encoded.
+	KDF KDF
+
+	// UKM is optional user keying material passed to the KDF.
+	UKM []byte
+
+	// Ciphertext is the RSA-KEM encapsulation.
+	Ciphertext []byte
+
+	// EncryptedKey is the wrapped content-encryption key.
+	EncryptedKey []byte
+
+	// KEKLength is the length in bytes of the AES key-encryption key: 16, 24 or 32.
+	KEKLength int
+}
+
+type asnKEMRecipientInfo struct {
+	Version      int
+	RID          asn1.RawValue
+	KEM          pkix.AlgorithmIdentifier
+	KEMCT        []byte
+	KDF          pkix.AlgorithmIdentifier
+	KEKLength    int
+	UKM          []byte `asn1:"optional,explicit,tag:0"`
+	Wrap         pkix.AlgorithmIdentifier
+	EncryptedKey []byte
+}
+
+type asnOtherRecipientInfo struct {
+	OriType  asn1.ObjectIdentifier
+	OriValue asnKEMRecipientInfo
+}
+
+// asnKEMOtherInfo is the CMSORIforKEMOtherInfo passed to the KDF as info.
+type asnKEMOtherInfo struct {
+	Wrap      pkix.AlgorithmIdentifier
+	KEKLength int
+	UKM       []byte `asn1:"optional,explicit,tag:0"`
+}
+
+// NewKEMRecipientInfo encapsulates a key-encryption key for pub with kdf, and wraps
+// the content-encryption key cek with it using AES-256 Key Wrap. cek must be a
+// multiple of 8 bytes long, and at least 16 bytes.
+func NewKEMRecipientInfo(pub *PublicKey, keyID []byte, kdf KDF, cek []byte) (*KEMRecipientInfo, error) {
+	ri := &KEMRecipientInfo{KeyID: keyID, KDF: kdf, KEKLength: 32}
+	info, err := ri.otherInfo()
+	if err != nil {
+		return nil, err
+	}
+	kek, c, err := pub.Encapsulate(kdf, info, ri.KEKLength)
+	if err != nil {
+		return nil, err
+	}
+	if ri.EncryptedKey, err = aesWrap(kek, cek); err != nil {
+		return nil, err
+	}
+	ri.Ciphertext = c
+	return ri, nil
+}
+
+// Open recovers the content-encryption key with the recipient's private key.
+func (ri *KEMRecipientInfo) Open(priv *PrivateKey) ([]byte, error) {
+	info, err := ri.otherInfo()
+	if err != nil {
+		return nil, err
+	}
+	kek, err := priv.Decapsulate(ri.KDF, ri.Ciphertext, info, ri.KEKLength)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := aesUnwrap(kek, ri.EncryptedKey)
+	if err != nil {
+		return nil, ErrDecryption
+	}
+	return cek, nil
+}
+
+// otherInfo returns the DER encoded CMSORIforKEMOtherInfo, or ErrRecipientInfo if ri
+// has no KDF to pass it to.
+func (ri *KEMRecipientInfo) otherInfo() ([]byte, error) {
+	if ri.KDF == nil {
+		return nil, ErrRecipientInfo
+	}
+	wrap, err := wrapAlgorithm(ri.KEKLength)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(asnKEMOtherInfo{Wrap: wrap, KEKLength: ri.KEKLength, UKM: ri.UKM})
+}
+
+// Marshal encodes ri as the ori alternative of a CMS RecipientInfo. The RSA-KEM
+// algorithm identifier is encoded without parameters, since the KDF and key length
+// have their own fields.
+func (ri *KEMRecipientInfo) Marshal() ([]byte, error) {
+	if ri.KDF == nil {
+		return nil, ErrRecipientInfo
+	}
+	kdf, err := kdfAlgorithm(ri.KDF)
+	if err != nil {
+		return nil, err
+	}
+	wrap, err := wrapAlgorithm(ri.KEKLength)
+	if err != nil {
+		return nil, err
+	}
+
+	// RecipientInfo ::= CHOICE { ..., ori [4] IMPLICIT OtherRecipientInfo }
+	return asn1.MarshalWithParams(asnOtherRecipientInfo{
+		OriType: oidKEMRecipientInfo,
+		OriValue: asnKEMRecipientInfo{
+			RID:          asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, Bytes: ri.KeyID},
+			KEM:          pkix.AlgorithmIdentifier{Algorithm: oidRSAKEM},
+			KEMCT:        ri.Ciphertext,
+			KDF:          kdf,
+			KEKLength:    ri.KEKLength,
+			UKM:          ri.UKM,
+			Wrap:         wrap,
+			EncryptedKey: ri.EncryptedKey,
+		},
+	}, "tag:4")
+}
+
+// Unmarshal parses a CMS RecipientInfo encoded by Marshal. Only recipients identified
+// by subject key identifier are supported.
+func (ri *KEMRecipientInfo) Unmarshal(b []byte) error {
+	var ori asnOtherRecipientInfo
+	rest, err := asn1.UnmarshalWithParams(b, &ori, "tag:4")
+	if err != nil || len(rest) != 0 || !ori.OriType.Equal(oidKEMRecipientInfo) {
+		return ErrRecipientInfo
+	}
+	v := ori.OriValue
+	if v.Version != 0 || !v.KEM.Algorithm.Equal(oidRSAKEM) {
+		return ErrRecipientInfo
+	}
+	if v.RID.Class != asn1.ClassContextSpecific || v.RID.Tag != 0 || v.RID.IsCompound {
+		return ErrRecipientInfo
+	}
+	kdf, err := parseKDF(v.KDF)
+	if err != nil {
+		return err
+	}
+	if wrap, err := wrapAlgorithm(v.KEKLength); err != nil || !wrap.Algorithm.Equal(v.Wrap.Algorithm) {
+		return ErrRecipientInfo
+	}
+
+	*ri = KEMRecipientInfo{
+		KeyID:        v.RID.Bytes,
+		KDF:          kdf,
+		UKM:          v.UKM,
+		Ciphertext:   v.KEMCT,
+		EncryptedKey: v.EncryptedKey,
+		KEKLength:    v.KEKLength,
+	}
+	return nil
+}
+
+func wrapAlgorithm(kekLength int) (pkix.AlgorithmIdentifier, error) {
+	var oid asn1.ObjectIdentifier
+	switch kekLength {
+	case 16:
+		oid = oidAES128Wrap
+	case 24:
+		oid = oidAES192Wrap
+	case 32:
+		oid = oidAES256Wrap
+	default:
+		return pkix.AlgorithmIdentifier{}, ErrRecipientInfo
+	}
+	return pkix.AlgorithmIdentifier{Algorithm: oid}, nil
+}
+
+func kdfAlgorithm(kdf KDF) (pkix.AlgorithmIdentifier, error) {
+	switch k := kdf.(type) {
+	case KDF2:
+		oid, ok := hashOIDs[k.Hash]
+		if !ok {
+			break
+		}
+		params, err := asn1.Marshal(pkix.AlgorithmIdentifier{Algorithm: oid})
+		if err != nil {
+			return pkix.AlgorithmIdentifier{}, err
+		}
+		return pkix.AlgorithmIdentifier{Algorithm: oidKDF2, Parameters: asn1.RawValue{FullBytes: params}}, nil
+	case HKDF:
+		if oid, ok := hkdfOIDs[k.Hash]; ok {
+			return pkix.AlgorithmIdentifier{Algorithm: oid}, nil
+		}
+	}
+	return pkix.AlgorithmIdentifier{}, ErrRecipientInfo
+}
+
+func parseKDF(a pkix.AlgorithmIdentifier) (KDF, error) {
+	if a.Algorithm.Equal(oidKDF2) {
+		var h pkix.AlgorithmIdentifier
+		if rest, err := asn1.Unmarshal(a.Parameters.FullBytes, &h); err != nil || len(rest) != 0 {
+			return nil, ErrRecipientInfo
+		}
+		for hash, oid := range hashOIDs {
+			if oid.Equal(h.Algorithm) {
+				return KDF2{Hash: hash}, nil
+			}
+		}
+		return nil, ErrRecipientInfo
+	}
+	for hash, oid := range hkdfOIDs {
+		if oid.Equal(a.Algorithm) {
+			return HKDF{Hash: hash}, nil
+		}
+	}
+	return nil, ErrRecipientInfo
+}
+
+// aesWrapIV is the default initial value of RFC 3394.
+var aesWrapIV = []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+
+// aesWrap wraps key with kek using the AES Key Wrap algorithm of RFC 3394.
+func aesWrap(kek, key []byte) ([]byte, error) {
+	if len(key)%8 != 0 || len(key) < 16 {
+		return nil, ErrEncoding
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(key) / 8
+	out := make([]byte, 8+len(key))
+	copy(out, aesWrapIV)
+	copy(out[8:], key)
+	b := make([]byte, 16)
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			copy(b, out[:8])
+			copy(b[8:], out[8*i:8*i+8])
+			block.Encrypt(b, b)
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(out, binary.BigEndian.Uint64(b)^t)
+			copy(out[8*i:], b[8:])
+		}
+	}
+	return out, nil
+}
+
+// aesUnwrap reverses aesWrap, and checks the integrity of the wrapped key.
+func aesUnwrap(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped)%8 != 0 || len(wrapped) < 24 {
+		return nil, ErrDecoding
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(wrapped)/8 - 1
+	out := append([]byte(nil), wrapped...)
+	b := make([]byte, 16)
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(b, binary.BigEndian.Uint64(out)^t)
+			copy(b[8:], out[8*i:8*i+8])
+			block.Decrypt(b, b)
+			copy(out, b[:8])
+			copy(out[8*i:], b[8:])
+		}
+	}
+	if subtle.ConstantTimeCompare(out[:8], aesWrapIV) != 1 {
+		return nil, ErrDecoding
+	}
+	return out[8:], nil
+}
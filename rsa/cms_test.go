@@ -0,0 +1,156 @@
+package rsa
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/asn1"
+	"encoding/hex"
+	"testing"
+
+	"github.com/mmussomele/crypto/rand"
+)
+
+func TestAESWrap(t *testing.T) {
+	// RFC 3394 sections 4.1 and 4.6.
+	for _, c := range []struct{ kek, key, wrapped string }{
+		{"000102030405060708090a0b0c0d0e0f", "00112233445566778899aabbccddeeff", "1fa68b0a8112b447aef34bd8fb5a7b829d3e862371d2cfe5"},
+		{
+			"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+			"00112233445566778899aabbccddeeff000102030405060708090a0b0c0d0e0f",
+			"28c9f404c4b810f4cbccb35cfb87f8263f5786e2d80ed326cbc7f0e71a99f43bfb988b9b7a02dd21",
+		},
+	} {
+		kek, _ := hex.DecodeString(c.kek)
+		key, _ := hex.DecodeString(c.key)
+		exp, _ := hex.DecodeString(c.wrapped)
+
+		w, err := aesWrap(kek, key)
+		switch {
+		case err != nil:
+			t.Fatalf("Failed to wrap key: %v", err)
+		case !bytes.Equal(w, exp):
+			t.Fatalf("Expected wrapped key %x, got %x", exp, w)
+		}
+		u, err := aesUnwrap(kek, w)
+		switch {
+		case err != nil:
+			t.Fatalf("Failed to unwrap key: %v", err)
+		case !bytes.Equal(u, key):
+			t.Fatalf("Expected unwrapped key %x, got %x", key, u)
+		}
+
+		w[len(w)-1] ^= 1
+		if _, err := aesUnwrap(kek, w); err != ErrDecoding {
+			t.Fatalf("Expected ErrDecoding, got %v", err)
+		}
+	}
+}
+
+func TestKEMRecipientInfo(t *testing.T) {
+	priv, err := NewKey(2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	cek := make([]byte, 32)
+	if _, err := rand.Read(cek); err != nil {
+		t.Fatalf("Failed to generate test key: %v", err)
+	}
+	keyID := []byte("subject key id")
+
+	for _, kdf := range []KDF{KDF2{crypto.SHA256}, KDF2{crypto.SHA1}, HKDF{crypto.SHA256}, HKDF{crypto.SHA512}} {
+		ri, err := NewKEMRecipientInfo(priv.PublicKey(), keyID, kdf, cek)
+		if err != nil {
+			t.Fatalf("Failed to create KEMRecipientInfo: %v", err)
+		}
+		b, err := ri.Marshal()
+		if err != nil {
+			t.Fatalf("Failed to encode KEMRecipientInfo: %v", err)
+		}
+
+		// The encoding is the ori alternative of RecipientInfo.
+		var raw asn1.RawValue
+		if _, err := asn1.Unmarshal(b, &raw); err != nil || raw.Class != asn1.ClassContextSpecific || raw.Tag != 4 {
+			t.Fatalf("Expected an [4] ori RecipientInfo, got %+v (%v)", raw, err)
+		}
+
+		var parsed KEMRecipientInfo
+		if err := parsed.Unmarshal(b); err != nil {
+			t.Fatalf("Failed to parse KEMRecipientInfo: %v", err)
+		}
+		if !bytes.Equal(parsed.KeyID, keyID) || parsed.KDF != kdf || parsed.KEKLength != 32 {
+			t.Fatalf("Parsed KEMRecipientInfo %+v does not match", parsed)
+		}
+		d, err := parsed.Open(priv)
+		switch {
+		case err != nil:
+			t.Fatalf("Failed to open KEMRecipientInfo: %v", err)
+		case !bytes.Equal(d, cek):
+			t.Fatal("Opened key did not match")
+		}
+
+		// The KEK length and UKM are bound to the derived key.
+		ukm := parsed
+		ukm.UKM = []byte("ukm")
+		if _, err := ukm.Open(priv); err != ErrDecryption {
+			t.Fatalf("Expected ErrDecryption with a different UKM, got %v", err)
+		}
+		short := parsed
+		short.KEKLength = 16
+		if _, err := short.Open(priv); err != ErrDecryption {
+			t.Fatalf("Expected ErrDecryption with a different KEK length, got %v", err)
+		}
+	}
+
+	// User keying material round trips.
+	ri, err := NewKEMRecipientInfo(priv.PublicKey(), keyID, HKDF{crypto.SHA256}, cek)
+	if err != nil {
+		t.Fatalf("Failed to create KEMRecipientInfo: %v", err)
+	}
+	ri.UKM = []byte("user keying material")
+	info, err := ri.otherInfo()
+	if err != nil {
+		t.Fatalf("Failed to encode KDF info: %v", err)
+	}
+	kek, c, err := priv.PublicKey().Encapsulate(ri.KDF, info, 32)
+	if err != nil {
+		t.Fatalf("Failed to encapsulate key: %v", err)
+	}
+	if ri.EncryptedKey, err = aesWrap(kek, cek); err != nil {
+		t.Fatalf("Failed to wrap key: %v", err)
+	}
+	ri.Ciphertext = c
+	b, err := ri.Marshal()
+	if err != nil {
+		t.Fatalf("Failed to encode KEMRecipientInfo: %v", err)
+	}
+	var parsed KEMRecipientInfo
+	if err := parsed.Unmarshal(b); err != nil {
+		t.Fatalf("Failed to parse KEMRecipientInfo: %v", err)
+	}
+	if d, err := parsed.Open(priv); err != nil || !bytes.Equal(d, cek) {
+		t.Fatalf("Failed to open KEMRecipientInfo with UKM: %v", err)
+	}
+
+	if _, err := (&KEMRecipientInfo{KDF: HKDF{crypto.SHA1}, KEKLength: 32}).Marshal(); err != ErrRecipientInfo {
+		t.Fatalf("Expected ErrRecipientInfo for an unsupported KDF, got %v", err)
+	}
+	noKDF := parsed
+	noKDF.KDF = nil
+	if _, err := noKDF.Marshal(); err != ErrRecipientInfo {
+		t.Fatalf("Expected ErrRecipientInfo for a missing KDF, got %v", err)
+	}
+	if _, err := noKDF.Open(priv); err != ErrRecipientInfo {
+		t.Fatalf("Expected ErrRecipientInfo for a missing KDF, got %v", err)
+	}
+	if _, err := NewKEMRecipientInfo(priv.PublicKey(), keyID, nil, cek); err != ErrRecipientInfo {
+		t.Fatalf("Expected ErrRecipientInfo for a missing KDF, got %v", err)
+	}
+	if _, err := NewKEMRecipientInfo(priv.PublicKey(), keyID, KDF2{crypto.SHA256}, cek[:12]); err != ErrEncoding {
+		t.Fatalf("Expected ErrEncoding for a short key, got %v", err)
+	}
+	for _, b := range [][]byte{nil, b[:len(b)-1], append(b, 0), []byte{0x30, 0x00}} {
+		if err := new(KEMRecipientInfo).Unmarshal(b); err != ErrRecipientInfo {
+			t.Fatalf("Expected ErrRecipientInfo, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package rsa
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// wycheproofHashes are the hash functions used by the Project Wycheproof RSAES-OAEP
+// vectors in testdata/wycheproof. PKCS #1 v1.5 and signature vectors are not
+// supported by this package.
+var wycheproofHashes = map[string]func() hash.Hash{
+	"SHA-1":       sha1.New,
+	"SHA-224":     sha256.New224,
+	"SHA-256":     sha256.New,
+	"SHA-384":     sha512.New384,
+	"SHA-512":     sha512.New,
+	"SHA-512/224": sha512.New512_224,
+	"SHA-512/256": sha512.New512_256,
+}
+
+type wycheproofFile struct {
+	Algorithm  string
+	TestGroups []struct {
+		Type            string
+		Sha             string
+		Mgf             string
+		MgfSha          string
+		PrivateKeyPkcs8 string
+		Tests           []struct {
+			TcID    int
+			Comment string
+			Flags   []string
+			Msg     string
+			Ct      string
+			Label   string
+			Result  string
+		}
+	}
+}
+
+func TestWycheproofOAEP(t *testing.T) {
+	files, err := filepath.Glob("testdata/wycheproof/rsa_*oaep_*_test.json")
+	switch {
+	case err != nil:
+		t.Fatalf("Failed to list test vectors: %v", err)
+	case len(files) == 0:
+		t.Fatal("No test vector files found")
+	}
+
+	results := make(map[string]int)
+	for _, file := range files {
+		var f wycheproofFile
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read test vectors: %v", err)
+		}
+		if err := json.Unmarshal(b, &f); err != nil {
+			t.Fatalf("Failed to parse %s: %v", file, err)
+		}
+
+		for _, g := range f.TestGroups {
+			h, mgfHash := wycheproofHashes[g.Sha], wycheproofHashes[g.MgfSha]
+			if g.Type != "RsaesOaepDecrypt" || g.Mgf != "MGF1" || h == nil || mgfHash == nil {
+				t.Fatalf("Unsupported %s group with %s and %s(%s) in %s", g.Type, g.Sha, g.Mgf, g.MgfSha, file)
+			}
+			priv := parsePKCS8(t, mustHex(t, g.PrivateKeyPkcs8))
+
+			for _, c := range g.Tests {
+				results[c.Result]++
+				msg, label := mustHex(t, c.Msg), mustHex(t, c.Label)
+				m, err := DecryptWithMGF(priv, h(), mgfHash(), mustHex(t, c.Ct), label)
+				switch {
+				case c.Result != "valid" && c.Result != "invalid" && c.Result != "acceptable":
+					t.Fatalf("%s #%d: unknown result %q", file, c.TcID, c.Result)
+				case c.Result == "invalid" && err == nil:
+					t.Fatalf("%s #%d (%s %v): decrypted invalid cipher text", file, c.TcID, c.Comment, c.Flags)
+				case c.Result == "valid" && err != nil:
+					t.Fatalf("%s #%d (%s %v): failed to decrypt: %v", file, c.TcID, c.Comment, c.Flags, err)
+				case err != nil && err != ErrDecryption && err != ErrCipherTextWrongLength:
+					t.Fatalf("%s #%d (%s %v): unexpected error %v", file, c.TcID, c.Comment, c.Flags, err)
+				case err == nil && !bytes.Equal(m, msg):
+					t.Fatalf("%s #%d (%s %v): expected %x, got %x", file, c.TcID, c.Comment, c.Flags, msg, m)
+				}
+			}
+		}
+	}
+	for _, r := range []string{"valid", "invalid", "acceptable"} {
+		if results[r] == 0 {
+			t.Fatalf("No %s test vectors were run", r)
+		}
+	}
+}
+
+// TestPKCS1OAEPVectors runs the RSA Laboratories PKCS #1 v2.1 OAEP examples. NIST
+// CAVP vectors are not included yet, see testdata/pkcs1/README.
+func TestPKCS1OAEPVectors(t *testing.T) {
+	var priv *PrivateKey
+	var count int
+	for _, r := range readResponseFile(t, "testdata/pkcs1/oaep-vect.rsp") {
+		if _, ok := r["n"]; ok {
+			priv = keyFromComponents(t, r)
+			continue
+		}
+		count++
+		msg, seed, ct := mustHex(t, r["Msg"]), mustHex(t, r["Seed"]), mustHex(t, r["CT"])
+
+		k := (priv.bits + 7) / 8
+		em, err := oaepEncode(sha1.New(), sha1.New(), msg, nil, seed, k)
+		if err != nil {
+			t.Fatalf("Failed to encode test message: %v", err)
+		}
+		c, err := encrypt(priv.PublicKey(), new(big.Int).SetBytes(em))
+		if err != nil {
+			t.Fatalf("Failed to encrypt test message: %v", err)
+		}
+		if c.Cmp(new(big.Int).SetBytes(ct)) != 0 {
+			t.Fatalf("Expected cipher text %x, got %x", ct, c)
+		}
+
+		m, err := Decrypt(priv, sha1.New(), ct, nil)
+		switch {
+		case err != nil:
+			t.Fatalf("Failed to decrypt test cipher text: %v", err)
+		case !bytes.Equal(m, msg):
+			t.Fatalf("Expected message %x, got %x", msg, m)
+		}
+	}
+	if count == 0 {
+		t.Fatal("No test vectors were run")
+	}
+}
+
+// readResponseFile parses a file in the layout of NIST CAVP response files into its
+// records, the groups of "name = value" lines separated by blank lines. The
+// "[name = value]" section headers are included in the records that follow them.
+func readResponseFile(t *testing.T, path string) []map[string]string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open test vectors: %v", err)
+	}
+	defer f.Close()
+
+	var records []map[string]string
+	section := make(map[string]string)
+	var record map[string]string
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		switch {
+		case line == "":
+			record = nil
+			continue
+		case strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			section = make(map[string]string)
+			if kv := strings.SplitN(line[1:len(line)-1], "=", 2); len(kv) == 2 {
+				section[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+			}
+			record = nil
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			t.Fatalf("Malformed line in %s: %q", path, line)
+		}
+		if record == nil {
+			record = make(map[string]string)
+			for k, v := range section {
+				record[k] = v
+			}
+			records = append(records, record)
+		}
+		record[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	if err := s.Err(); err != nil {
+		t.Fatalf("Failed to read test vectors: %v", err)
+	}
+	return records
+}
+
+// keyFromComponents builds a private key from the hexadecimal n, e, d, p and q of r
+// by encoding it and parsing it with Unmarshal.
+func keyFromComponents(t *testing.T, r map[string]string) *PrivateKey {
+	t.Helper()
+	v := make(map[string]*big.Int)
+	for _, name := range []string{"n", "e", "d", "p", "q"} {
+		x, ok := new(big.Int).SetString(r[name], 16)
+		if !ok {
+			t.Fatalf("Invalid key component %s = %q", name, r[name])
+		}
+		v[name] = x
+	}
+
+	p1 := new(big.Int).Sub(v["p"], one)
+	q1 := new(big.Int).Sub(v["q"], one)
+	b, err := asn1.Marshal(asnPrivateKey{
+		N:    v["n"],
+		E:    int(v["e"].Int64()),
+		D:    v["d"],
+		P:    v["p"],
+		Q:    v["q"],
+		Dp:   new(big.Int).Mod(v["d"], p1),
+		Dq:   new(big.Int).Mod(v["d"], q1),
+		QInv: new(big.Int).ModInverse(v["q"], v["p"]),
+	})
+	if err != nil {
+		t.Fatalf("Failed to encode key: %v", err)
+	}
+
+	priv := new(PrivateKey)
+	if err := priv.Unmarshal(b); err != nil {
+		t.Fatalf("Failed to parse key: %v", err)
+	}
+	if mod := r["mod"]; mod != "" && mod != big.NewInt(int64(priv.bits)).String() {
+		t.Fatalf("Expected a %s bit key, got %d bits", mod, priv.bits)
+	}
+	return priv
+}
+
+// parsePKCS8 parses an unencrypted PKCS #8 RSA private key with Unmarshal.
+func parsePKCS8(t *testing.T, der []byte) *PrivateKey {
+	t.Helper()
+	var pkcs8 struct {
+		Version    int
+		Algorithm  asn1.RawValue
+		PrivateKey []byte
+	}
+	if _, err := asn1.Unmarshal(der, &pkcs8); err != nil {
+		t.Fatalf("Failed to parse PKCS #8 key: %v", err)
+	}
+
+	priv := new(PrivateKey)
+	if err := priv.Unmarshal(pkcs8.PrivateKey); err != nil {
+		t.Fatalf("Failed to parse key: %v", err)
+	}
+	return priv
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("Invalid hex %q: %v", s, err)
+	}
+	return b
+}
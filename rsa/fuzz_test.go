@@ -0,0 +1,143 @@
+//go:build go1.18
+// +build go1.18
+
+package rsa
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"math/big"
+	"testing"
+
+	"github.com/mmussomele/crypto/rand"
+)
+
+func fuzzKey(f *testing.F) *PrivateKey {
+	f.Helper()
+	priv, err := NewKey(1024)
+	if err != nil {
+		f.Fatalf("Failed to generate key: %v", err)
+	}
+	return priv
+}
+
+func FuzzUnmarshal(f *testing.F) {
+	b := fuzzKey(f).Marshal()
+	f.Add(b)
+	f.Add(b[:len(b)/2])
+	f.Add(append(b, 0))
+	f.Add([]byte{0x30, 0x03, 0x02, 0x01, 0x00})
+	goPriv, err := rsa.GenerateKey(rand.Reader(), 1024)
+	if err != nil {
+		f.Fatalf("Failed to generate key: %v", err)
+	}
+	f.Add(x509.MarshalPKCS1PrivateKey(goPriv))
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		priv := new(PrivateKey)
+		if err := priv.Unmarshal(b); err != nil {
+			return
+		}
+		if m := priv.Marshal(); !bytes.Equal(m, b) {
+			t.Fatalf("Marshal did not round trip: %x != %x", m, b)
+		}
+		if _, err := x509.ParsePKCS1PrivateKey(b); err != nil {
+			t.Fatalf("Parsed a key rejected by x509: %v", err)
+		}
+
+		// The primes are not checked, so decryption only round trips when they
+		// really are prime.
+		ps := []*big.Int{priv.p, priv.q}
+		for _, o := range priv.others {
+			ps = append(ps, o.R)
+		}
+		for _, p := range ps {
+			if !p.ProbablyPrime(20) {
+				return
+			}
+		}
+		m, err := rand.Int(priv.n)
+		if err != nil {
+			t.Fatalf("Failed to generate test message: %v", err)
+		}
+		c, _ := encrypt(priv.PublicKey(), m)
+		if d := decrypt(priv, c); d.Cmp(m) != 0 {
+			t.Fatalf("Decrypted %s, expected %s", d, m)
+		}
+	})
+}
+
+func FuzzOAEPDecode(f *testing.F) {
+	h := sha256.New()
+	seed := make([]byte, h.Size())
+	for _, m := range []string{"", "a", "attack at dawn"} {
+		em, err := oaepEncode(h, h, []byte(m), nil, seed, 128)
+		if err != nil {
+			f.Fatalf("Failed to encode test message: %v", err)
+		}
+		f.Add(em, []byte(nil))
+	}
+	f.Add(make([]byte, 2*h.Size()+2), []byte("label"))
+	f.Add([]byte{}, []byte{})
+
+	f.Fuzz(func(t *testing.T, em, p []byte) {
+		orig := append([]byte(nil), em...)
+		m, err := oaepDecode(h, h, em, p)
+		if err != nil {
+			return
+		}
+
+		// Decoding unmasks the seed in place, so encoding the message again with
+		// it must give the original encoding.
+		enc, err := oaepEncode(h, h, m, p, em[1:1+h.Size()], len(orig))
+		switch {
+		case err != nil:
+			t.Fatalf("Failed to encode decoded message: %v", err)
+		case !bytes.Equal(enc, orig):
+			t.Fatalf("Encoding did not round trip: %x != %x", enc, orig)
+		}
+	})
+}
+
+func FuzzDecrypt(f *testing.F) {
+	priv := fuzzKey(f)
+	goPriv, err := x509.ParsePKCS1PrivateKey(priv.Marshal())
+	if err != nil {
+		f.Fatalf("Failed to parse key: %v", err)
+	}
+
+	h := sha256.New()
+	c, err := Encrypt(priv.PublicKey(), h, []byte("attack at dawn"), []byte("label"))
+	if err != nil {
+		f.Fatalf("Failed to encrypt test message: %v", err)
+	}
+	f.Add(c, []byte("label"))
+	f.Add(c, []byte(nil))
+	f.Add(c[1:], []byte("label"))
+	f.Add(priv.n.Bytes(), []byte(nil))
+	f.Add(make([]byte, len(c)), []byte(nil))
+
+	f.Fuzz(func(t *testing.T, c, p []byte) {
+		m, err := Decrypt(priv, h, c, p)
+		goM, goErr := rsa.DecryptOAEP(sha256.New(), nil, goPriv, c, p)
+		switch {
+		case (err == nil) != (goErr == nil):
+			t.Fatalf("Decrypt returned %v, crypto/rsa returned %v", err, goErr)
+		case !bytes.Equal(m, goM):
+			t.Fatalf("Decrypted %x, crypto/rsa decrypted %x", m, goM)
+		}
+		if err != nil {
+			return
+		}
+
+		c2, err := Encrypt(priv.PublicKey(), h, m, p)
+		if err != nil {
+			t.Fatalf("Failed to encrypt decrypted message: %v", err)
+		}
+		if m2, err := Decrypt(priv, h, c2, p); err != nil || !bytes.Equal(m2, m) {
+			t.Fatalf("Encryption did not round trip: %x, %v", m2, err)
+		}
+	})
+}
@@ -0,0 +1,355 @@
+package rsa
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/mmussomele/crypto/rand"
+)
+
+// Envelopes encrypt a message of any length for one or more recipients. The message
+// is encrypted with a random AES-256-GCM key, which is encrypted for each recipient
+// with OAEP and SHA-256. Version 1 envelopes have the header
+//
+//	"RSAE" || version (1 byte) || chunk size (4 bytes) || nonce prefix (7 bytes) ||
+//	recipient count (2 bytes) || recipients
+//
+// where each recipient is the SHA-256 hash of its PKCS #1 public key, followed by the
+// length (2 bytes) and value of its encrypted key. Integers are big-endian.
+//
+// The header is followed by the message in chunks of chunk size bytes, each sealed
+// with the header as additional data. The nonce of chunk i is the nonce prefix
+// followed by i (4 bytes) and a byte which is 1 for the last chunk and 0 otherwise,
+// as in the STREAM construction, so chunks cannot be reordered, dropped or
+// truncated without detection. The last chunk may be shorter than chunk size bytes,
+// or empty.
+const (
+	envelopeVersion   = 1
+	envelopeChunkSize = 64 << 10
+	maxChunkSize      = 16 << 20
+	noncePrefixSize   = 7
+	keyIDSize         = sha256.Size
+)
+
+var envelopeMagic = []byte("RSAE")
+
+// envelopeLabel is the OAEP label of the encrypted keys.
+var envelopeLabel = []byte("crypto/rsa envelope")
+
+// Envelope errors.
+var (
+	ErrEnvelope        = errors.New("crypto/rsa: malformed envelope")
+	ErrEnvelopeVersion = errors.New("crypto/rsa: unsupported envelope version")
+	ErrNotRecipient    = errors.New("crypto/rsa: key is not a recipient of the envelope")
+	ErrSealerClosed    = errors.New("crypto/rsa: write to closed sealer")
+	ErrRecipients      = errors.New("crypto/rsa: envelopes must have between 1 and 65535 recipients")
+)
+
+// Seal encrypts m for each of the recipients, any of which can recover it with Open.
+// There must be between 1 and 65535 recipients.
+func Seal(recipients []*PublicKey, m []byte) ([]byte, error) {
+	var b bytes.Buffer
+	s, err := NewSealer(&b, recipients)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.Write(m); err != nil {
+		return nil, err
+	}
+	if err := s.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Open decrypts an envelope created by Seal or NewSealer with the private key of one
+// of its recipients.
+func Open(priv *PrivateKey, envelope []byte) ([]byte, error) {
+	r, err := NewOpener(bytes.NewReader(envelope), priv)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// keyID identifies a recipient by the SHA-256 hash of its PKCS #1 public key.
+func keyID(pub *PublicKey) []byte {
+	b, err := asn1.Marshal(struct {
+		N *big.Int
+		E int
+	}{pub.n, int(pub.e.Int64())})
+	if err != nil {
+		panic(err) // should never fail
+	}
+	id := sha256.Sum256(b)
+	return id[:]
+}
+
+// envelopeNonce returns the nonce of chunk i.
+func envelopeNonce(nonce, prefix []byte, i uint32, last bool) []byte {
+	nonce = append(nonce[:0], prefix...)
+	nonce = append(nonce, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(nonce[noncePrefixSize:], i)
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+type sealer struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	header []byte
+	prefix []byte
+	nonce  []byte
+	buf    []byte
+	out    []byte
+	chunk  int
+	i      uint32
+	closed bool
+}
+
+// NewSealer returns a writer which encrypts a message for each of the recipients and
+// writes the envelope to w. The message is written in chunks, so that messages of
+// any size can be encrypted in constant memory. Close must be called to write the
+// last chunk, or the envelope cannot be opened.
+func NewSealer(w io.Writer, recipients []*PublicKey) (io.WriteCloser, error) {
+	s, err := newSealer(w, recipients, envelopeChunkSize)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func newSealer(w io.Writer, recipients []*PublicKey, chunk int) (*sealer, error) {
+	if len(recipients) == 0 || len(recipients) > 0xffff {
+		return nil, ErrRecipients
+	}
+
+	key := make([]byte, 32)
+	prefix := make([]byte, noncePrefixSize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+
+	header := append([]byte(nil), envelopeMagic...)
+	header = append(header, envelopeVersion, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(header[len(header)-4:], uint32(chunk))
+	header = append(header, prefix...)
+	header = append(header, byte(len(recipients)>>8), byte(len(recipients)))
+	h := sha256.New()
+	for _, pub := range recipients {
+		wrapped, err := Encrypt(pub, h, key, envelopeLabel)
+		if err != nil {
+			return nil, err
+		}
+		header = append(header, keyID(pub)...)
+		header = append(header, byte(len(wrapped)>>8), byte(len(wrapped)))
+		header = append(header, wrapped...)
+	}
+
+	aead, err := newEnvelopeAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &sealer{
+		w:      w,
+		aead:   aead,
+		header: header,
+		prefix: prefix,
+		chunk:  chunk,
+		buf:    make([]byte, 0, chunk),
+	}, nil
+}
+
+func newEnvelopeAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Write encrypts b. Chunks are only written once they are known not to be the last.
+func (s *sealer) Write(b []byte) (int, error) {
+	if s.closed {
+		return 0, ErrSealerClosed
+	}
+	n := len(b)
+	for len(b) > 0 {
+		if len(s.buf) == s.chunk {
+			if err := s.flush(false); err != nil {
+				return n - len(b), err
+			}
+		}
+		k := copy(s.buf[len(s.buf):s.chunk], b)
+		s.buf = s.buf[:len(s.buf)+k]
+		b = b[k:]
+	}
+	return n, nil
+}
+
+// Close writes the last chunk. It does not close the underlying writer.
+func (s *sealer) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.flush(true)
+}
+
+func (s *sealer) flush(last bool) error {
+	if s.i == 1<<32-1 && !last {
+		return ErrMessageTooLarge
+	}
+	s.nonce = envelopeNonce(s.nonce, s.prefix, s.i, last)
+	s.out = s.aead.Seal(s.out[:0], s.nonce, s.buf, s.header)
+	s.buf = s.buf[:0]
+	s.i++
+	_, err := s.w.Write(s.out)
+	return err
+}
+
+type opener struct {
+	r      io.Reader
+	aead   cipher.AEAD
+	header []byte
+	prefix []byte
+	nonce  []byte
+	in     []byte // sealed bytes read ahead of the current chunk
+	buf    []byte // opened bytes not yet returned
+	chunk  int
+	i      uint32
+	done   bool
+	err    error
+}
+
+// NewOpener reads the header of an envelope from r and returns a reader of the
+// decrypted message. Each chunk is authenticated before any of it is returned, but
+// the message is only complete once the reader returns io.EOF: an envelope which is
+// truncated or otherwise modified causes a read to fail with ErrDecryption.
+func NewOpener(r io.Reader, priv *PrivateKey) (io.Reader, error) {
+	fixed := make([]byte, len(envelopeMagic)+1+4+noncePrefixSize+2)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, ErrEnvelope
+	}
+	if !bytes.HasPrefix(fixed, envelopeMagic) {
+		return nil, ErrEnvelope
+	}
+	if fixed[len(envelopeMagic)] != envelopeVersion {
+		return nil, ErrEnvelopeVersion
+	}
+	rest := fixed[len(envelopeMagic)+1:]
+	chunk := binary.BigEndian.Uint32(rest)
+	if chunk == 0 || chunk > maxChunkSize {
+		return nil, ErrEnvelope
+	}
+	prefix := rest[4 : 4+noncePrefixSize]
+	count := int(binary.BigEndian.Uint16(rest[4+noncePrefixSize:]))
+	if count == 0 {
+		return nil, ErrEnvelope
+	}
+
+	header := fixed
+	id := keyID(priv.PublicKey())
+	var wrapped []byte
+	for i := 0; i < count; i++ {
+		start := len(header)
+		header = append(header, make([]byte, keyIDSize+2)...)
+		if _, err := io.ReadFull(r, header[start:]); err != nil {
+			return nil, ErrEnvelope
+		}
+		l := int(binary.BigEndian.Uint16(header[len(header)-2:]))
+		header = append(header, make([]byte, l)...)
+		if _, err := io.ReadFull(r, header[len(header)-l:]); err != nil {
+			return nil, ErrEnvelope
+		}
+		if wrapped == nil && bytes.Equal(header[start:start+keyIDSize], id) {
+			wrapped = header[len(header)-l:]
+		}
+	}
+	if wrapped == nil {
+		return nil, ErrNotRecipient
+	}
+
+	key, err := Decrypt(priv, sha256.New(), wrapped, envelopeLabel)
+	if err != nil || len(key) != 32 {
+		return nil, ErrDecryption
+	}
+	aead, err := newEnvelopeAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &opener{
+		r:      r,
+		aead:   aead,
+		header: header,
+		prefix: prefix,
+		chunk:  int(chunk),
+	}, nil
+}
+
+func (o *opener) Read(b []byte) (int, error) {
+	for len(o.buf) == 0 {
+		switch {
+		case o.err != nil:
+			return 0, o.err
+		case o.done:
+			return 0, io.EOF
+		}
+		o.err = o.next()
+	}
+	n := copy(b, o.buf)
+	o.buf = o.buf[n:]
+	return n, nil
+}
+
+// next opens the next chunk. A chunk is the last one if it is not followed by any
+// more bytes, so one byte past it is read ahead.
+func (o *opener) next() error {
+	sealed := o.chunk + o.aead.Overhead()
+	if cap(o.in) < sealed+1 {
+		in := make([]byte, len(o.in), sealed+1)
+		copy(in, o.in)
+		o.in = in
+	}
+	n, err := io.ReadFull(o.r, o.in[len(o.in):sealed+1])
+	o.in = o.in[:len(o.in)+n]
+	switch err {
+	case nil, io.EOF, io.ErrUnexpectedEOF:
+	default:
+		return err
+	}
+
+	last := len(o.in) <= sealed
+	if !last && o.i == 1<<32-1 {
+		return ErrDecryption
+	}
+	c := o.in
+	if !last {
+		c = o.in[:sealed]
+	}
+	o.nonce = envelopeNonce(o.nonce, o.prefix, o.i, last)
+	m, err := o.aead.Open(nil, o.nonce, c, o.header)
+	if err != nil {
+		return ErrDecryption
+	}
+	o.i++
+	o.buf = m
+	o.in = append(o.in[:0], o.in[len(c):]...)
+	o.done = last
+	return nil
+}
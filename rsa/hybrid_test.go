@@ -0,0 +1,202 @@
+package rsa
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/mmussomele/crypto/rand"
+)
+
+func TestSealOpen(t *testing.T) {
+	var privs []*PrivateKey
+	var pubs []*PublicKey
+	for _, size := range []int{1024, 2048} {
+		priv, err := NewKey(size)
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		privs = append(privs, priv)
+		pubs = append(pubs, priv.PublicKey())
+	}
+
+	for _, size := range []int{0, 1, 1000, envelopeChunkSize, envelopeChunkSize + 1, 3*envelopeChunkSize + 17} {
+		m := make([]byte, size)
+		if _, err := rand.Read(m); err != nil {
+			t.Fatalf("Failed to generate test message: %v", err)
+		}
+		env, err := Seal(pubs, m)
+		if err != nil {
+			t.Fatalf("Failed to seal test message: %v", err)
+		}
+		for _, priv := range privs {
+			d, err := Open(priv, env)
+			switch {
+			case err != nil:
+				t.Fatalf("Failed to open envelope: %v", err)
+			case !bytes.Equal(d, m):
+				t.Fatal("Opened message did not match original")
+			}
+		}
+	}
+
+	other, err := NewKey(1024)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	env, err := Seal(pubs[:1], []byte("secret"))
+	if err != nil {
+		t.Fatalf("Failed to seal test message: %v", err)
+	}
+	if _, err := Open(other, env); err != ErrNotRecipient {
+		t.Fatalf("Expected ErrNotRecipient, got %v", err)
+	}
+	if _, err := Open(privs[1], env); err != ErrNotRecipient {
+		t.Fatalf("Expected ErrNotRecipient, got %v", err)
+	}
+
+	if _, err := Seal(nil, []byte("secret")); err != ErrRecipients {
+		t.Fatalf("Expected ErrRecipients without recipients, got %v", err)
+	}
+	many := make([]*PublicKey, 0x10000)
+	for i := range many {
+		many[i] = pubs[0]
+	}
+	if _, err := NewSealer(new(bytes.Buffer), many); err != ErrRecipients {
+		t.Fatalf("Expected ErrRecipients for %d recipients, got %v", len(many), err)
+	}
+}
+
+func TestSealerStreaming(t *testing.T) {
+	priv, err := NewKey(1024)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	// Write in pieces which straddle the chunk boundaries, and read back in pieces
+	// of a different size.
+	const chunk = 100
+	for _, size := range []int{0, 99, 100, 101, 1000, 1234} {
+		m := make([]byte, size)
+		if _, err := rand.Read(m); err != nil {
+			t.Fatalf("Failed to generate test message: %v", err)
+		}
+
+		var env bytes.Buffer
+		s, err := newSealer(&env, []*PublicKey{priv.PublicKey()}, chunk)
+		if err != nil {
+			t.Fatalf("Failed to create sealer: %v", err)
+		}
+		for rest := m; len(rest) > 0; {
+			n := 37
+			if n > len(rest) {
+				n = len(rest)
+			}
+			if _, err := s.Write(rest[:n]); err != nil {
+				t.Fatalf("Failed to write test message: %v", err)
+			}
+			rest = rest[n:]
+		}
+		if err := s.Close(); err != nil {
+			t.Fatalf("Failed to close sealer: %v", err)
+		}
+		if _, err := s.Write([]byte{0}); err != ErrSealerClosed {
+			t.Fatalf("Expected ErrSealerClosed, got %v", err)
+		}
+
+		r, err := NewOpener(bytes.NewReader(env.Bytes()), priv)
+		if err != nil {
+			t.Fatalf("Failed to open envelope: %v", err)
+		}
+		var d bytes.Buffer
+		if _, err := io.CopyBuffer(&d, struct{ io.Reader }{r}, make([]byte, 53)); err != nil {
+			t.Fatalf("Failed to read envelope: %v", err)
+		}
+		if !bytes.Equal(d.Bytes(), m) {
+			t.Fatal("Opened message did not match original")
+		}
+	}
+}
+
+func TestOpenModified(t *testing.T) {
+	priv, err := NewKey(1024)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	m := make([]byte, 450)
+	if _, err := rand.Read(m); err != nil {
+		t.Fatalf("Failed to generate test message: %v", err)
+	}
+
+	// Chunks of 100 bytes seal to 116 bytes, so the envelope ends with four full
+	// chunks and a last chunk of 50 bytes.
+	var b bytes.Buffer
+	s, err := newSealer(&b, []*PublicKey{priv.PublicKey()}, 100)
+	if err != nil {
+		t.Fatalf("Failed to create sealer: %v", err)
+	}
+	s.Write(m)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Failed to close sealer: %v", err)
+	}
+	env := b.Bytes()
+	body := len(env) - 4*116 - 66
+	if _, err := Open(priv, env); err != nil {
+		t.Fatalf("Failed to open envelope: %v", err)
+	}
+
+	modified := func(edit func(e []byte) []byte) []byte {
+		return edit(append([]byte(nil), env...))
+	}
+	for _, c := range []struct {
+		name string
+		env  []byte
+		err  error
+	}{
+		{"flipped header bit", modified(func(e []byte) []byte { e[10] ^= 1; return e }), ErrDecryption},
+		{"flipped body bit", modified(func(e []byte) []byte { e[body+200] ^= 1; return e }), ErrDecryption},
+		{"dropped last chunk", env[:len(env)-66], ErrDecryption},
+		{"dropped middle chunk", modified(func(e []byte) []byte {
+			return append(e[:body+116], e[body+2*116:]...)
+		}), ErrDecryption},
+		{"swapped chunks", modified(func(e []byte) []byte {
+			c := append([]byte(nil), e[body:body+116]...)
+			copy(e[body:], e[body+116:body+2*116])
+			copy(e[body+116:], c)
+			return e
+		}), ErrDecryption},
+		{"truncated last chunk", env[:len(env)-1], ErrDecryption},
+		{"appended byte", append(modified(func(e []byte) []byte { return e }), 0), ErrDecryption},
+		{"truncated header", env[:20], ErrEnvelope},
+		{"wrong magic", modified(func(e []byte) []byte { e[0] = 'X'; return e }), ErrEnvelope},
+		{"wrong version", modified(func(e []byte) []byte { e[4] = 2; return e }), ErrEnvelopeVersion},
+		{"oversized chunks", modified(func(e []byte) []byte { e[5] = 0xff; return e }), ErrEnvelope},
+	} {
+		if _, err := Open(priv, c.env); err != c.err {
+			t.Fatalf("%s: expected %v, got %v", c.name, c.err, err)
+		}
+	}
+
+	// The chunks before a modification are returned before the error.
+	r, err := NewOpener(bytes.NewReader(env[:len(env)-1]), priv)
+	if err != nil {
+		t.Fatalf("Failed to open envelope: %v", err)
+	}
+	d, err := io.ReadAll(r)
+	if err != ErrDecryption || !bytes.Equal(d, m[:400]) {
+		t.Fatalf("Expected 400 bytes and ErrDecryption, got %d bytes and %v", len(d), err)
+	}
+}
+
+func BenchmarkSeal(b *testing.B) {
+	priv, err := NewKey(2048)
+	if err != nil {
+		b.Fatalf("Failed to generate key: %v", err)
+	}
+	m := make([]byte, 1<<20)
+	b.SetBytes(int64(len(m)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Seal([]*PublicKey{priv.PublicKey()}, m)
+	}
+}
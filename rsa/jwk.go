@@ -0,0 +1,356 @@
+package rsa
+
+import (
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// ErrJWK is returned when a JSON Web Key is malformed or is not an RSA key.
+var ErrJWK = errors.New("crypto/rsa: invalid JSON web key")
+
+// JWK is an RSA JSON Web Key, as specified by RFC 7517 and RFC 7518.
+type JWK struct {
+	// Public is the public key.
+	Public *PublicKey
+
+	// Private is the private key, or nil if the JWK only holds the public key.
+	Private *PrivateKey
+
+	// KeyID, Use, Algorithm and KeyOps are the "kid", "use", "alg" and "key_ops"
+	// parameters, which are omitted if empty.
+	KeyID     string
+	Use       string
+	Algorithm string
+	KeyOps    []string
+}
+
+type jsonWebKey struct {
+	Kty    string           `json:"kty"`
+	Kid    string           `json:"kid,omitempty"`
+	Use    string           `json:"use,omitempty"`
+	Alg    string           `json:"alg,omitempty"`
+	KeyOps []string         `json:"key_ops,omitempty"`
+	N      string           `json:"n"`
+	E      string           `json:"e"`
+	D      string           `json:"d,omitempty"`
+	P      string           `json:"p,omitempty"`
+	Q      string           `json:"q,omitempty"`
+	DP     string           `json:"dp,omitempty"`
+	DQ     string           `json:"dq,omitempty"`
+	QI     string           `json:"qi,omitempty"`
+	Oth    []jsonOtherPrime `json:"oth,omitempty"`
+}
+
+// jsonOtherPrime is an element of the "oth" array of a multi-prime key.
+type jsonOtherPrime struct {
+	R string `json:"r"`
+	D string `json:"d"`
+	T string `json:"t"`
+}
+
+// The member names of jsonWebKey and jsonOtherPrime.
+var (
+	jwkMembers        = []string{"kty", "kid", "use", "alg", "key_ops", "n", "e", "d", "p", "q", "dp", "dq", "qi", "oth"}
+	otherPrimeMembers = []string{"r", "d", "t"}
+)
+
+// MarshalJSON encodes k as a JWK. Private keys include all their CRT parameters, and
+// the further primes of multi-prime keys.
+func (k JWK) MarshalJSON() ([]byte, error) {
+	pub := k.Public
+	if k.Private != nil {
+		pub = k.Private.PublicKey()
+	}
+	if pub == nil {
+		return nil, ErrJWK
+	}
+
+	j := jsonWebKey{
+		Kty:    "RSA",
+		Kid:    k.KeyID,
+		Use:    k.Use,
+		Alg:    k.Algorithm,
+		KeyOps: k.KeyOps,
+		N:      encodeJWKInt(pub.n),
+		E:      encodeJWKInt(pub.e),
+	}
+	if p := k.Private; p != nil {
+		j.D = encodeJWKInt(p.d)
+		j.P = encodeJWKInt(p.p)
+		j.Q = encodeJWKInt(p.q)
+		j.DP = encodeJWKInt(p.dP)
+		j.DQ = encodeJWKInt(p.dQ)
+		j.QI = encodeJWKInt(p.qInv)
+		for _, o := range p.others {
+			j.Oth = append(j.Oth, jsonOtherPrime{
+				R: encodeJWKInt(o.R),
+				D: encodeJWKInt(o.D),
+				T: encodeJWKInt(o.T),
+			})
+		}
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON parses an RSA JWK. Member names are case-sensitive, the integers
+// must be base64url encoded without padding or leading zeros, and the private
+// parameters must be consistent with each other. If the CRT parameters of a two-prime
+// key are omitted, they are recovered from n, e and d.
+func (k *JWK) UnmarshalJSON(b []byte) error {
+	members, err := checkMembers(b, jwkMembers)
+	if err != nil {
+		return err
+	}
+	if oth, ok := members["oth"]; ok {
+		var others []json.RawMessage
+		if err := json.Unmarshal(oth, &others); err != nil {
+			return err
+		}
+		for _, o := range others {
+			if _, err := checkMembers(o, otherPrimeMembers); err != nil {
+				return err
+			}
+		}
+	}
+
+	var j jsonWebKey
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	if j.Kty != "RSA" {
+		return ErrJWK
+	}
+
+	n, err := decodeJWKInt(j.N)
+	if err != nil {
+		return err
+	}
+	e, err := decodeJWKInt(j.E)
+	if err != nil {
+		return err
+	}
+	if e.Cmp(big.NewInt(3)) < 0 || e.Bit(0) == 0 || e.BitLen() > 31 || n.Bit(0) == 0 {
+		return ErrJWK
+	}
+
+	*k = JWK{
+		Public:    &PublicKey{n: n, e: e, bits: n.BitLen()},
+		KeyID:     j.Kid,
+		Use:       j.Use,
+		Algorithm: j.Alg,
+		KeyOps:    j.KeyOps,
+	}
+	if j.D == "" {
+		if j.P != "" || j.Q != "" || j.DP != "" || j.DQ != "" || j.QI != "" || j.Oth != nil {
+			return ErrJWK
+		}
+		return nil
+	}
+
+	asnp := asnPrivateKey{N: n, E: int(e.Int64())}
+	if asnp.D, err = decodeJWKInt(j.D); err != nil {
+		return err
+	}
+	switch crt := []string{j.P, j.Q, j.DP, j.DQ, j.QI}; {
+	case strings.Join(crt, "") == "":
+		// Only the primes of two-prime keys can be recovered.
+		if j.Oth != nil {
+			return ErrJWK
+		}
+		if asnp.P, asnp.Q = recoverPrimes(n, e, asnp.D); asnp.P == nil {
+			return ErrInvalidKey
+		}
+		p1 := new(big.Int).Sub(asnp.P, one)
+		q1 := new(big.Int).Sub(asnp.Q, one)
+		asnp.Dp = new(big.Int).Mod(asnp.D, p1)
+		asnp.Dq = new(big.Int).Mod(asnp.D, q1)
+		asnp.QInv = new(big.Int).ModInverse(asnp.Q, asnp.P)
+	default:
+		// The CRT parameters must all be present if any are.
+		var ints [5]*big.Int
+		for i, s := range crt {
+			if ints[i], err = decodeJWKInt(s); err != nil {
+				return err
+			}
+		}
+		asnp.P, asnp.Q, asnp.Dp, asnp.Dq, asnp.QInv = ints[0], ints[1], ints[2], ints[3], ints[4]
+	}
+	// "oth" is absent from two-prime keys, rather than empty.
+	if j.Oth != nil && len(j.Oth) == 0 {
+		return ErrJWK
+	}
+	for _, o := range j.Oth {
+		var c crtPrime
+		for _, x := range []struct {
+			v **big.Int
+			s string
+		}{{&c.R, o.R}, {&c.D, o.D}, {&c.T, o.T}} {
+			if *x.v, err = decodeJWKInt(x.s); err != nil {
+				return err
+			}
+		}
+		asnp.Others = append(asnp.Others, c)
+	}
+	if err := validate(&asnp); err != nil {
+		return err
+	}
+
+	k.Private = &PrivateKey{
+		n:      n,
+		e:      e,
+		d:      asnp.D,
+		p:      asnp.P,
+		q:      asnp.Q,
+		dP:     asnp.Dp,
+		dQ:     asnp.Dq,
+		qInv:   asnp.QInv,
+		others: asnp.Others,
+		bits:   n.BitLen(),
+	}
+	return nil
+}
+
+// checkMembers returns the members of the JSON object b, or ErrJWK if the name of one
+// of them differs from one of names only in case. encoding/json matches member names
+// case-insensitively, but JWK member names are case-sensitive, and other members must
+// be ignored rather than taken for the expected ones.
+func checkMembers(b []byte, names []string) (map[string]json.RawMessage, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(b, &members); err != nil {
+		return nil, err
+	}
+	for m := range members {
+		for _, name := range names {
+			if m != name && strings.EqualFold(m, name) {
+				return nil, ErrJWK
+			}
+		}
+	}
+	return members, nil
+}
+
+// recoverPrimes factors n given the exponents e and d, or returns nil if they are
+// not consistent. Since ed-1 is a multiple of lambda(n), for most g there is a
+// square root of 1 other than 1 and -1 among g^((ed-1)/2^i), which reveals a factor.
+func recoverPrimes(n, e, d *big.Int) (p, q *big.Int) {
+	k := new(big.Int).Mul(e, d)
+	k.Sub(k, one)
+	if k.Sign() <= 0 {
+		return nil, nil
+	}
+	t := k.TrailingZeroBits()
+	r := new(big.Int).Rsh(k, t)
+	n1 := new(big.Int).Sub(n, one)
+
+	y, x := new(big.Int), new(big.Int)
+	for g := int64(2); g < 100; g++ {
+		y.Exp(big.NewInt(g), r, n)
+		for i := uint(0); i < t; i++ {
+			x.Mul(y, y).Mod(x, n)
+			if x.Cmp(one) == 0 && y.Cmp(one) != 0 && y.Cmp(n1) != 0 {
+				p = new(big.Int).GCD(nil, nil, y.Sub(y, one), n)
+				q = new(big.Int).Div(n, p)
+				if p.Cmp(q) < 0 {
+					p, q = q, p
+				}
+				return p, q
+			}
+			y.Set(x)
+		}
+	}
+	return nil, nil
+}
+
+// encodeJWKInt encodes x as a base64url encoded big-endian integer.
+func encodeJWKInt(x *big.Int) string {
+	b := x.Bytes()
+	if len(b) == 0 {
+		b = []byte{0}
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeJWKInt decodes a positive integer encoded by encodeJWKInt. It rejects
+// padding, line breaks, non-zero trailing bits and leading zero bytes, so that each
+// integer has a single encoding.
+func decodeJWKInt(s string) (*big.Int, error) {
+	if strings.ContainsAny(s, "\r\n") {
+		return nil, ErrJWK
+	}
+	b, err := base64.RawURLEncoding.Strict().DecodeString(s)
+	if err != nil || len(b) == 0 || b[0] == 0 {
+		return nil, ErrJWK
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// Thumbprint returns the RFC 7638 thumbprint of the public key: the hash of its
+// required JWK members in lexicographic order, without whitespace.
+func (pub *PublicKey) Thumbprint(h crypto.Hash) []byte {
+	if !h.Available() {
+		panic("crypto/rsa: thumbprint hash function is not available")
+	}
+	s := `{"e":"` + encodeJWKInt(pub.e) + `","kty":"RSA","n":"` + encodeJWKInt(pub.n) + `"}`
+	d := h.New()
+	d.Write([]byte(s))
+	return d.Sum(nil)
+}
+
+// JWKSet is a JWK Set, as specified by RFC 7517.
+type JWKSet struct {
+	Keys []*JWK `json:"keys"`
+}
+
+// UnmarshalJSON parses a JWK Set. Keys of types other than RSA are skipped, as
+// allowed by RFC 7517, but malformed RSA keys are errors.
+func (s *JWKSet) UnmarshalJSON(b []byte) error {
+	if _, err := checkMembers(b, []string{"keys"}); err != nil {
+		return err
+	}
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &set); err != nil {
+		return err
+	}
+	if set.Keys == nil {
+		return ErrJWK
+	}
+
+	keys := make([]*JWK, 0, len(set.Keys))
+	for _, raw := range set.Keys {
+		if _, err := checkMembers(raw, []string{"kty"}); err != nil {
+			return err
+		}
+		var kty struct {
+			Kty string `json:"kty"`
+		}
+		if err := json.Unmarshal(raw, &kty); err != nil {
+			return err
+		}
+		if kty.Kty != "RSA" {
+			continue
+		}
+		k := new(JWK)
+		if err := k.UnmarshalJSON(raw); err != nil {
+			return err
+		}
+		keys = append(keys, k)
+	}
+	s.Keys = keys
+	return nil
+}
+
+// Lookup returns the first key with the key ID kid, or nil if there is none.
+func (s *JWKSet) Lookup(kid string) *JWK {
+	for _, k := range s.Keys {
+		if k.KeyID == kid {
+			return k
+		}
+	}
+	return nil
+}
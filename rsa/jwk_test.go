@@ -0,0 +1,302 @@
+package rsa
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+)
+
+// rfcJWK is the RSA private key of RFC 7517 appendix A.2, whose public key is the
+// example of RFC 7638 section 3.1.
+const rfcJWK = `{"kty":"RSA",
+ "n":"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+ "e":"AQAB",
+ "d":"X4cTteJY_gn4FYPsXB8rdXix5vwsg1FLN5E3EaG6RJoVH-HLLKD9M7dx5oo7GURknchnrRweUkC7hT5fJLM0WbFAKNLWY2vv7B6NqXSzUvxT0_YSfqijwp3RTzlBaCxWp4doFk5N2o8Gy_nHNKroADIkJ46pRUohsXywbReAdYaMwFs9tv8d_cPVY3i07a3t8MN6TNwm0dSawm9v47UiCl3Sk5ZiG7xojPLu4sbg1U2jx4IBTNBznbJSzFHK66jT8bgkuqsk0GjskDJk19Z4qwjwbsnn4j2WBii3RL-Us2lGVkY8fkFzme1z0HbIkfz0Y6mqnOYtqc0X4jfcKoAC8Q",
+ "p":"83i-7IvMGXoMXCskv73TKr8637FiO7Z27zv8oj6pbWUQyLPQBQxtPVnwD20R-60eTDmD2ujnMt5PoqMrm8RfmNhVWDtjjMmCMjOpSXicFHj7XOuVIYQyqVWlWEh6dN36GVZYk93N8Bc9vY41xy8B9RzzOGVQzXvNEvn7O0nVbfs",
+ "q":"3dfOR9cuYq-0S-mkFLzgItgMEfFzB2q3hWehMuG0oCuqnb3vobLyumqjVZQO1dIrdwgTnCdpYzBcOfW5r370AFXjiWft_NGEiovonizhKpo9VVS78TzFgxkIdrecRezsZ-1kYd_s1qDbxtkDEgfAITAG9LUnADun4vIcb6yelxk",
+ "dp":"G4sPXkc6Ya9y8oJW9_ILj4xuppu0lzi_H7VTkS8xj5SdX3coE0oimYwxIi2emTAue0UOa5dpgFGyBJ4c8tQ2VF402XRugKDTP8akYhFo5tAA77Qe_NmtuYZc3C3m3I24G2GvR5sSDxUyAN2zq8Lfn9EUms6rY3Ob8YeiKkTiBj0",
+ "dq":"s9lAH9fggBsoFR8Oac2R_E2gw282rT2kGOAhvIllETE1efrA6huUUvMfBcMpn8lqeW6vzznYY5SSQF7pMdC_agI3nG8Ibp1BUb0JUiraRNqUfLhcQb_d9GF4Dh7e74WbRsobRonujTYN1xCaP6TO61jvWrX-L18txXw494Q_cgk",
+ "qi":"GyM_p6JrXySiz1toFgKbWV-JdI3jQ4ypu9rbMWx3rQJBfmt0FoYzgUIZEVFEcOqwemRN81zoDAaa-Bk0KWNGDjJHZDdDmFhW3AN7lI-puxk_mHZGJ11rxyR8O55XLSe3SPmRfKwZI6yU24ZxvQKFYItdldUKGzO6Ia6zTKhAVRU",
+ "alg":"RS256",
+ "kid":"2011-04-29"}`
+
+func parseRFCJWK(t *testing.T) (*JWK, map[string]interface{}) {
+	var k JWK
+	if err := json.Unmarshal([]byte(rfcJWK), &k); err != nil {
+		t.Fatalf("Failed to parse JWK: %v", err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(rfcJWK), &m); err != nil {
+		t.Fatalf("Failed to parse JSON: %v", err)
+	}
+	return &k, m
+}
+
+// withMembers returns the RFC key with the given members replaced, or removed if nil.
+func withMembers(t *testing.T, members map[string]interface{}) []byte {
+	_, m := parseRFCJWK(t)
+	for k, v := range members {
+		if v == nil {
+			delete(m, k)
+		} else {
+			m[k] = v
+		}
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("Failed to encode JSON: %v", err)
+	}
+	return b
+}
+
+func TestJWK(t *testing.T) {
+	k, m := parseRFCJWK(t)
+	if k.Private == nil || k.KeyID != "2011-04-29" || k.Algorithm != "RS256" || k.Public.bits != 2048 {
+		t.Fatalf("Parsed JWK %+v does not match", k)
+	}
+
+	// Encoding reproduces the RFC key exactly.
+	b, err := json.Marshal(k)
+	if err != nil {
+		t.Fatalf("Failed to encode JWK: %v", err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatalf("Failed to parse JSON: %v", err)
+	}
+	if len(out) != len(m) {
+		t.Fatalf("Expected %d members, got %d", len(m), len(out))
+	}
+	for name, v := range m {
+		if out[name] != v {
+			t.Fatalf("Expected %q to be %v, got %v", name, v, out[name])
+		}
+	}
+
+	// JWK values encode like pointers.
+	if v, err := json.Marshal(*k); err != nil || string(v) != string(b) {
+		t.Fatalf("Expected %s from a JWK value, got %s (%v)", b, v, err)
+	}
+
+	// A null "oth" is the same as none.
+	var null JWK
+	if err := json.Unmarshal(withMembers(t, map[string]interface{}{"oth": json.RawMessage("null")}), &null); err != nil || null.Private == nil {
+		t.Fatalf("Failed to parse JWK with null oth: %v", err)
+	}
+
+	// The public key alone omits the private members.
+	b, err = json.Marshal(&JWK{Public: k.Public})
+	if err != nil {
+		t.Fatalf("Failed to encode JWK: %v", err)
+	}
+	exp := `{"kty":"RSA","n":"` + m["n"].(string) + `","e":"AQAB"}`
+	if string(b) != exp {
+		t.Fatalf("Expected %s, got %s", exp, b)
+	}
+	var pub JWK
+	if err := json.Unmarshal(b, &pub); err != nil {
+		t.Fatalf("Failed to parse JWK: %v", err)
+	}
+	if pub.Private != nil || pub.Public.n.Cmp(k.Public.n) != 0 {
+		t.Fatalf("Parsed public JWK %+v does not match", pub)
+	}
+
+	// The parsed key decrypts what its public key encrypts.
+	c, err := Encrypt(pub.Public, sha256.New(), []byte("jwk"), nil)
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	if d, err := Decrypt(k.Private, sha256.New(), c, nil); err != nil || string(d) != "jwk" {
+		t.Fatalf("Failed to decrypt with parsed key: %v", err)
+	}
+}
+
+func TestJWKMultiPrime(t *testing.T) {
+	var f wycheproofFile
+	b, err := os.ReadFile("testdata/wycheproof/rsa_three_primes_oaep_2048_sha1_mgf1sha1_test.json")
+	if err != nil {
+		t.Fatalf("Failed to read test key: %v", err)
+	}
+	if err := json.Unmarshal(b, &f); err != nil {
+		t.Fatalf("Failed to parse test key: %v", err)
+	}
+	priv := parsePKCS8(t, mustHex(t, f.TestGroups[0].PrivateKeyPkcs8))
+	if len(priv.others) != 1 {
+		t.Fatalf("Expected a three-prime key, got %d further primes", len(priv.others))
+	}
+
+	b, err = json.Marshal(JWK{Private: priv})
+	if err != nil {
+		t.Fatalf("Failed to encode JWK: %v", err)
+	}
+	var m struct {
+		Oth []map[string]string
+	}
+	if err := json.Unmarshal(b, &m); err != nil || len(m.Oth) != 1 || len(m.Oth[0]) != 3 {
+		t.Fatalf("Expected one oth member with r, d and t, got %s (%v)", b, err)
+	}
+	var k JWK
+	if err := json.Unmarshal(b, &k); err != nil {
+		t.Fatalf("Failed to parse JWK: %v", err)
+	}
+	if !bytes.Equal(k.Private.Marshal(), priv.Marshal()) {
+		t.Fatal("Parsed multi-prime key does not match")
+	}
+
+	c, err := Encrypt(k.Public, sha256.New(), []byte("three primes"), nil)
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	if d, err := Decrypt(k.Private, sha256.New(), c, nil); err != nil || string(d) != "three primes" {
+		t.Fatalf("Failed to decrypt with parsed key: %v", err)
+	}
+
+	// Each further prime must be consistent with the rest of the key.
+	k.Private.others[0].T = new(big.Int).Add(k.Private.others[0].T, one)
+	if b, err = json.Marshal(k); err != nil {
+		t.Fatalf("Failed to encode JWK: %v", err)
+	}
+	if err := json.Unmarshal(b, new(JWK)); err != ErrInvalidKey {
+		t.Fatalf("Expected ErrInvalidKey, got %v", err)
+	}
+}
+
+func TestJWKRecoverPrimes(t *testing.T) {
+	k, _ := parseRFCJWK(t)
+	b := withMembers(t, map[string]interface{}{"p": nil, "q": nil, "dp": nil, "dq": nil, "qi": nil})
+	var r JWK
+	if err := json.Unmarshal(b, &r); err != nil {
+		t.Fatalf("Failed to parse JWK without CRT parameters: %v", err)
+	}
+	for i, x := range [][2]*big.Int{
+		{r.Private.p, k.Private.p},
+		{r.Private.q, k.Private.q},
+		{r.Private.dP, k.Private.dP},
+		{r.Private.dQ, k.Private.dQ},
+		{r.Private.qInv, k.Private.qInv},
+	} {
+		if x[0].Cmp(x[1]) != 0 {
+			t.Fatalf("Recovered CRT parameter %d does not match", i)
+		}
+	}
+}
+
+func TestJWKInvalid(t *testing.T) {
+	_, m := parseRFCJWK(t)
+	n := m["n"].(string)
+	d := m["d"].(string)
+
+	for _, c := range []struct {
+		name    string
+		members map[string]interface{}
+		err     error
+	}{
+		{"kty", map[string]interface{}{"kty": "EC"}, ErrJWK},
+		{"missing kty", map[string]interface{}{"kty": nil}, ErrJWK},
+		{"missing n", map[string]interface{}{"n": nil}, ErrJWK},
+		{"padding", map[string]interface{}{"e": "AQAB="}, ErrJWK},
+		{"standard alphabet", map[string]interface{}{"n": strings.Replace(n, "_", "/", 1)}, ErrJWK},
+		{"line break", map[string]interface{}{"n": n[:64] + "\n" + n[64:]}, ErrJWK},
+		{"trailing bits", map[string]interface{}{"e": "AQABAR"}, ErrJWK},
+		{"leading zero", map[string]interface{}{"e": "AAEAAQ"}, ErrJWK},
+		{"empty", map[string]interface{}{"e": ""}, ErrJWK},
+		{"even e", map[string]interface{}{"e": "AQAA"}, ErrJWK},
+		{"small e", map[string]interface{}{"e": "AQ"}, ErrJWK},
+		{"partial CRT", map[string]interface{}{"qi": nil}, ErrJWK},
+		{"CRT without d", map[string]interface{}{"d": nil}, ErrJWK},
+		{"wrong d", map[string]interface{}{"d": d[:len(d)-2] + "AQ"}, ErrInvalidKey},
+		{"swapped primes", map[string]interface{}{"p": m["q"], "q": m["p"]}, ErrInvalidKey},
+		{"empty oth", map[string]interface{}{"oth": []interface{}{}}, ErrJWK},
+		{"oth without d", map[string]interface{}{"d": nil, "p": nil, "q": nil, "dp": nil, "dq": nil, "qi": nil, "oth": []interface{}{map[string]interface{}{"r": "Aw", "d": "AQ", "t": "AQ"}}}, ErrJWK},
+		{"oth without CRT", map[string]interface{}{"p": nil, "q": nil, "dp": nil, "dq": nil, "qi": nil, "oth": []interface{}{map[string]interface{}{"r": "Aw", "d": "AQ", "t": "AQ"}}}, ErrJWK},
+		{"oth missing t", map[string]interface{}{"oth": []interface{}{map[string]interface{}{"r": "Aw", "d": "AQ"}}}, ErrJWK},
+		{"inconsistent oth", map[string]interface{}{"oth": []interface{}{map[string]interface{}{"r": "Aw", "d": "AQ", "t": "AQ"}}}, ErrInvalidKey},
+		{"upper case n", map[string]interface{}{"n": nil, "N": n}, ErrJWK},
+		{"upper case d", map[string]interface{}{"d": nil, "D": d}, ErrJWK},
+		{"mixed case kty", map[string]interface{}{"kty": nil, "Kty": "RSA"}, ErrJWK},
+		{"duplicate in upper case", map[string]interface{}{"E": "AQAB"}, ErrJWK},
+		{"upper case key_ops", map[string]interface{}{"KEY_OPS": []string{"sign"}}, ErrJWK},
+		{"upper case oth member", map[string]interface{}{"oth": []interface{}{map[string]interface{}{"R": "Aw", "d": "AQ", "t": "AQ"}}}, ErrJWK},
+	} {
+		var k JWK
+		if err := json.Unmarshal(withMembers(t, c.members), &k); err != c.err {
+			t.Fatalf("Expected %v for %s, got %v", c.err, c.name, err)
+		}
+	}
+
+	// Unknown members are ignored.
+	if err := json.Unmarshal(withMembers(t, map[string]interface{}{"x5t": "abc", "ext": true}), new(JWK)); err != nil {
+		t.Fatalf("Failed to parse JWK with unknown members: %v", err)
+	}
+
+	// A d that is inconsistent with n and e can not be used to recover the primes.
+	b := withMembers(t, map[string]interface{}{"d": "AQAB", "p": nil, "q": nil, "dp": nil, "dq": nil, "qi": nil})
+	if err := json.Unmarshal(b, new(JWK)); err != ErrInvalidKey {
+		t.Fatalf("Expected ErrInvalidKey, got %v", err)
+	}
+}
+
+func TestThumbprint(t *testing.T) {
+	k, _ := parseRFCJWK(t)
+	// RFC 7638 section 3.1.
+	exp := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"
+	for _, pub := range []*PublicKey{k.Public, k.Private.PublicKey()} {
+		tp := base64.RawURLEncoding.EncodeToString(pub.Thumbprint(crypto.SHA256))
+		if tp != exp {
+			t.Fatalf("Expected thumbprint %s, got %s", exp, tp)
+		}
+	}
+	if len(k.Public.Thumbprint(crypto.SHA512)) != 64 {
+		t.Fatal("Unexpected SHA-512 thumbprint length")
+	}
+}
+
+func TestJWKSet(t *testing.T) {
+	k, _ := parseRFCJWK(t)
+	priv, err := NewKey(1024)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	set := JWKSet{Keys: []*JWK{
+		{Public: k.Public, KeyID: "2011-04-29", Use: "sig"},
+		{Private: priv, KeyID: "new", KeyOps: []string{"decrypt", "unwrapKey"}},
+	}}
+	b, err := json.Marshal(&set)
+	if err != nil {
+		t.Fatalf("Failed to encode JWK Set: %v", err)
+	}
+
+	// Keys of other types are skipped.
+	ec := `{"kty":"EC","crv":"P-256","x":"MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4","y":"4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyM","kid":"1"}`
+	b = bytes.Replace(b, []byte(`"keys":[`), []byte(`"keys":[`+ec+`,`), 1)
+
+	var parsed JWKSet
+	if err := json.Unmarshal(b, &parsed); err != nil {
+		t.Fatalf("Failed to parse JWK Set: %v", err)
+	}
+	if len(parsed.Keys) != 2 {
+		t.Fatalf("Expected 2 keys, got %d", len(parsed.Keys))
+	}
+	if k := parsed.Lookup("2011-04-29"); k == nil || k.Private != nil || k.Use != "sig" {
+		t.Fatalf("Unexpected key %+v", k)
+	}
+	if k := parsed.Lookup("new"); k == nil || k.Private == nil || k.Private.d.Cmp(priv.d) != 0 || len(k.KeyOps) != 2 {
+		t.Fatalf("Unexpected key %+v", k)
+	}
+	if parsed.Lookup("1") != nil {
+		t.Fatal("Found a key that is not an RSA key")
+	}
+
+	for _, s := range []string{`{}`, `{"keys":[{"kty":"RSA","n":"AQAB"}]}`, `{"Keys":[]}`, `{"keys":[{"KTY":"EC"}]}`} {
+		if err := json.Unmarshal([]byte(s), new(JWKSet)); err != ErrJWK {
+			t.Fatalf("Expected ErrJWK for %s, got %v", s, err)
+		}
+	}
+}
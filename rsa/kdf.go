@@ -0,0 +1,82 @@
+package rsa
+
+import (
+	"crypto"
+	"crypto/hmac"
+	_ "crypto/sha1" // register the hashes used by KDFs
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/binary"
+	"errors"
+)
+
+// ErrKeyLength is returned when a KDF cannot derive a key of the requested length.
+var ErrKeyLength = errors.New("crypto/rsa: invalid derived key length")
+
+// A KDF derives a key of l bytes from the shared secret z and the context info.
+type KDF interface {
+	Derive(z, info []byte, l int) ([]byte, error)
+}
+
+// KDF2 is the KDF2 key derivation function of ISO 18033-2, also specified by ANSI
+// X9.44 and X9.63, using the given hash.
+type KDF2 struct {
+	Hash crypto.Hash
+}
+
+// Derive returns the first l bytes of Hash(z || 1 || info) || Hash(z || 2 || info) ||
+// ..., where the counters are 4 byte big-endian integers.
+func (k KDF2) Derive(z, info []byte, l int) ([]byte, error) {
+	if !k.Hash.Available() {
+		panic("crypto/rsa: KDF2 hash function is not available")
+	}
+	if l < 0 || uint64(l) > uint64(k.Hash.Size())*(1<<32-1) {
+		return nil, ErrKeyLength
+	}
+
+	h := k.Hash.New()
+	out := make([]byte, 0, l+h.Size())
+	var counter [4]byte
+	for i := uint32(1); len(out) < l; i++ {
+		binary.BigEndian.PutUint32(counter[:], i)
+		h.Reset()
+		h.Write(z)
+		h.Write(counter[:])
+		h.Write(info)
+		out = h.Sum(out)
+	}
+	return out[:l], nil
+}
+
+// HKDF is the HMAC-based key derivation function of RFC 5869 using the given hash,
+// with an empty salt.
+type HKDF struct {
+	Hash crypto.Hash
+}
+
+// Derive extracts a pseudorandom key from z and expands it to l bytes with info.
+func (k HKDF) Derive(z, info []byte, l int) ([]byte, error) {
+	if !k.Hash.Available() {
+		panic("crypto/rsa: HKDF hash function is not available")
+	}
+	if l < 0 || l > 255*k.Hash.Size() {
+		return nil, ErrKeyLength
+	}
+
+	// An empty salt is a string of hash length zeros.
+	extract := hmac.New(k.Hash.New, make([]byte, k.Hash.Size()))
+	extract.Write(z)
+	expand := hmac.New(k.Hash.New, extract.Sum(nil))
+
+	out := make([]byte, 0, l+k.Hash.Size())
+	var t []byte
+	for i := 1; len(out) < l; i++ {
+		expand.Reset()
+		expand.Write(t)
+		expand.Write(info)
+		expand.Write([]byte{byte(i)})
+		t = expand.Sum(t[:0])
+		out = append(out, t...)
+	}
+	return out[:l], nil
+}
@@ -0,0 +1,56 @@
+package rsa
+
+import (
+	"math/big"
+
+	"github.com/mmussomele/crypto/rand"
+)
+
+// Encapsulate generates a key of l bytes with RSA-KEM, as specified by ISO 18033-2
+// and RFC 5990, and returns it with its encapsulation c. A uniformly random z modulo
+// n is encrypted to give c, and the key is derived from z with kdf and info. The
+// same kdf and info must be passed to Decapsulate.
+func (pub *PublicKey) Encapsulate(kdf KDF, info []byte, l int) (key, c []byte, err error) {
+	z, err := rand.Int(pub.n)
+	if err != nil {
+		return nil, nil, err
+	}
+	bc, err := encrypt(pub, z)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keySize := (pub.bits + 7) / 8
+	key, err = kdf.Derive(leftPad(z, keySize), info, l)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, leftPad(bc, keySize), nil
+}
+
+// Decapsulate recovers the key of l bytes encapsulated by c with Encapsulate.
+func (priv *PrivateKey) Decapsulate(kdf KDF, c, info []byte, l int) ([]byte, error) {
+	keySize := (priv.bits + 7) / 8
+	if len(c) != keySize {
+		return nil, ErrCipherTextWrongLength
+	}
+	bc := new(big.Int).SetBytes(c)
+	if bc.Cmp(priv.n) >= 0 {
+		return nil, ErrDecryption
+	}
+
+	z, err := decryptBlinded(priv, bc)
+	if err != nil {
+		return nil, err
+	}
+	return kdf.Derive(leftPad(z, keySize), info, l)
+}
+
+// leftPad returns x as a big-endian byte string of length l.
+func leftPad(x *big.Int, l int) []byte {
+	b := x.Bytes()
+	if len(b) < l {
+		b = append(make([]byte, l-len(b)), b...)
+	}
+	return b
+}
@@ -0,0 +1,113 @@
+package rsa
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/hex"
+	"testing"
+)
+
+func TestKDF(t *testing.T) {
+	z := make([]byte, 32)
+	for i := range z {
+		z[i] = byte(i)
+	}
+	info := []byte("crypto/rsa KDF2 test")
+	ikm := bytes.Repeat([]byte{0x0b}, 22)
+
+	// The KDF2 vectors were computed with the X9.63 KDF of pyca/cryptography, and the
+	// first HKDF vector is test case 3 of RFC 5869.
+	for _, c := range []struct {
+		kdf     KDF
+		z, info []byte
+		exp     string
+	}{
+		{KDF2{crypto.SHA1}, z, info, "ffd9abf8f350412d22f3df4e10a8b14edb7ae6f65e3676aac4adf69ff98984e46bdd3bc782b2b77c31d57c79f7012c5b37f5a7618848b94baf743fe5cc71ca3d48a7425875a4"},
+		{KDF2{crypto.SHA256}, z, info, "2d7398848b40b8372c2818ae6ef682bd354bba57a88f73c2bfe32c3328f97562f5e45991c6a1b13a9b43f78f1af01487dfaf855be34a28c861a233bc48fc359c5a96f63b8db7"},
+		{KDF2{crypto.SHA512}, z, info, "d8237f6fcad43b88b5b414d8a282d373158b0217aa6d577bb462cef0598309f48b2689df59476fdff7e5824f470c89a70909853d1c4a2bb96b4d7dd2198f3b4ad658d7824d27"},
+		{HKDF{crypto.SHA256}, ikm, nil, "8da4e775a563c18f715f802a063c5a31b8a11f5c5ee1879ec3454e5f3c738d2d9d201395faa4b61a96c8"},
+		{HKDF{crypto.SHA384}, z, info, "a671c77449592b94bc252962d15e2ef80b06933f10fd62d86d419fd3bc8a7309483f489e52b8edc18be67d8480b542f9dce9"},
+	} {
+		exp, _ := hex.DecodeString(c.exp)
+		for _, l := range []int{0, 1, len(exp)} {
+			k, err := c.kdf.Derive(c.z, c.info, l)
+			switch {
+			case err != nil:
+				t.Fatalf("Failed to derive key: %v", err)
+			case !bytes.Equal(k, exp[:l]):
+				t.Fatalf("Expected %x from %T, got %x", exp[:l], c.kdf, k)
+			}
+		}
+	}
+
+	if _, err := (HKDF{crypto.SHA256}).Derive(z, nil, 255*32+1); err != ErrKeyLength {
+		t.Fatalf("Expected ErrKeyLength, got %v", err)
+	}
+	if _, err := (KDF2{crypto.SHA256}).Derive(z, nil, -1); err != ErrKeyLength {
+		t.Fatalf("Expected ErrKeyLength, got %v", err)
+	}
+}
+
+func TestKEM(t *testing.T) {
+	for _, size := range []int{512, 1029, 2048} {
+		priv, err := NewKey(size)
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		pub := priv.PublicKey()
+
+		for _, kdf := range []KDF{KDF2{crypto.SHA256}, HKDF{crypto.SHA512}} {
+			info := []byte("kem test")
+			key, c, err := pub.Encapsulate(kdf, info, 32)
+			if err != nil {
+				t.Fatalf("Failed to encapsulate key: %v", err)
+			}
+			if len(key) != 32 || len(c) != (size+7)/8 {
+				t.Fatalf("Unexpected key length %d or encapsulation length %d", len(key), len(c))
+			}
+
+			d, err := priv.Decapsulate(kdf, c, info, 32)
+			switch {
+			case err != nil:
+				t.Fatalf("Failed to decapsulate key: %v", err)
+			case !bytes.Equal(d, key):
+				t.Fatal("Decapsulated key did not match")
+			}
+
+			// A different encapsulation or info gives an unrelated key.
+			key2, c2, err := pub.Encapsulate(kdf, info, 32)
+			if err != nil {
+				t.Fatalf("Failed to encapsulate key: %v", err)
+			}
+			if bytes.Equal(key, key2) || bytes.Equal(c, c2) {
+				t.Fatal("Encapsulated the same key twice")
+			}
+			if d, err := priv.Decapsulate(kdf, c, []byte("other"), 32); err != nil || bytes.Equal(d, key) {
+				t.Fatalf("Expected a different key for different info, got %v", err)
+			}
+
+			if _, err := priv.Decapsulate(kdf, c[1:], info, 32); err != ErrCipherTextWrongLength {
+				t.Fatalf("Expected ErrCipherTextWrongLength, got %v", err)
+			}
+			if _, err := priv.Decapsulate(kdf, leftPad(priv.n, len(c)), info, 32); err != ErrDecryption {
+				t.Fatalf("Expected ErrDecryption, got %v", err)
+			}
+		}
+	}
+}
+
+func BenchmarkDecapsulate(b *testing.B) {
+	priv, err := NewKey(2048)
+	if err != nil {
+		b.Fatalf("Failed to generate key: %v", err)
+	}
+	kdf := KDF2{crypto.SHA256}
+	_, c, err := priv.PublicKey().Encapsulate(kdf, nil, 32)
+	if err != nil {
+		b.Fatalf("Failed to encapsulate key: %v", err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		priv.Decapsulate(kdf, c, nil, 32)
+	}
+}
@@ -3,7 +3,7 @@
 package rsa
 
 import (
-	"bytes"
+	"crypto/subtle"
 	"encoding/asn1"
 	"encoding/binary"
 	"errors"
@@ -14,7 +14,8 @@ import (
 	"github.com/mmussomele/crypto/rand"
 )
 
-// PrivateKey is an RSA private key.
+// PrivateKey is an RSA private key. Keys with more than two primes, as specified by
+// RFC 8017, can be parsed but are never generated.
 type PrivateKey struct {
 	n    *big.Int
 	e    *big.Int
@@ -25,7 +26,14 @@ type PrivateKey struct {
 	dQ   *big.Int
 	qInv *big.Int
 
+	// The third and later primes of a multi-prime key.
+	others []crtPrime
+
 	bits int
+
+	// Primality certificates for p and q, if they were generated provably.
+	pCert *primes.Certificate
+	qCert *primes.Certificate
 }
 
 // PublicKey returns the public parameters of p.
@@ -37,6 +45,21 @@ func (p *PrivateKey) PublicKey() *PublicKey {
 	}
 }
 
+// Certificates returns certificates proving that the secret primes p and q are prime.
+// They are only available for keys generated with the Provable method, and are nil
+// otherwise.
+func (p *PrivateKey) Certificates() (pCert, qCert *primes.Certificate) {
+	return p.pCert, p.qCert
+}
+
+// A crtPrime is the i-th prime R of a multi-prime key, for i >= 3, with its CRT
+// exponent D = d (mod R-1) and coefficient T = (r_1 * ... * r_(i-1))^-1 (mod R).
+type crtPrime struct {
+	R *big.Int
+	D *big.Int
+	T *big.Int
+}
+
 type asnPrivateKey struct {
 	Version int
 	N       *big.Int
@@ -47,12 +70,17 @@ type asnPrivateKey struct {
 	Dp      *big.Int
 	Dq      *big.Int
 	QInv    *big.Int
+	Others  []crtPrime `asn1:"optional,omitempty"`
 }
 
 // Marshal encodes the PrivateKey in ASN.1 DER format.
 func (p *PrivateKey) Marshal() []byte {
+	version := 0
+	if len(p.others) > 0 {
+		version = 1
+	}
 	b, err := asn1.Marshal(asnPrivateKey{
-		Version: 0,
+		Version: version,
 		N:       p.n,
 		E:       int(p.e.Int64()),
 		D:       p.d,
@@ -61,6 +89,7 @@ func (p *PrivateKey) Marshal() []byte {
 		Dp:      p.dP,
 		Dq:      p.dQ,
 		QInv:    p.qInv,
+		Others:  p.others,
 	})
 	if err != nil {
 		panic(err) // should never fail
@@ -68,15 +97,27 @@ func (p *PrivateKey) Marshal() []byte {
 	return b
 }
 
-// Unmarshal attempts to parse a private key from the bytes.
+// Unmarshal attempts to parse a private key from the bytes. It returns ErrInvalidKey
+// if the key parameters are not consistent with each other.
 func (p *PrivateKey) Unmarshal(b []byte) error {
 	var asnp asnPrivateKey
-	if _, err := asn1.Unmarshal(b, &asnp); err != nil {
+	rest, err := asn1.Unmarshal(b, &asnp)
+	if err != nil {
 		return err
 	}
-	if asnp.Version != 0 {
+	if len(rest) != 0 {
+		return ErrInvalidKey
+	}
+	if asnp.Version > 1 {
 		return ErrUnsupportedKey
 	}
+	// Version 1 keys have more than two primes.
+	if (asnp.Version == 1) != (len(asnp.Others) > 0) {
+		return ErrInvalidKey
+	}
+	if err := validate(&asnp); err != nil {
+		return err
+	}
 	p.n = asnp.N
 	p.e = big.NewInt(int64(asnp.E))
 	p.d = asnp.D
@@ -85,6 +126,63 @@ func (p *PrivateKey) Unmarshal(b []byte) error {
 	p.dP = asnp.Dp
 	p.dQ = asnp.Dq
 	p.qInv = asnp.QInv
+	p.others = asnp.Others
+	p.bits = p.n.BitLen()
+	return nil
+}
+
+// validate checks that n is the product of the primes and that the exponents and CRT
+// coefficients match them. The primality of the primes is not checked.
+func validate(k *asnPrivateKey) error {
+	for _, x := range []*big.Int{k.N, k.D, k.P, k.Q, k.Dp, k.Dq, k.QInv} {
+		if x.Sign() <= 0 {
+			return ErrInvalidKey
+		}
+	}
+	if k.E < 3 || k.E > 1<<31-1 || k.P.Cmp(one) <= 0 || k.Q.Cmp(one) <= 0 {
+		return ErrInvalidKey
+	}
+
+	e := big.NewInt(int64(k.E))
+	x := new(big.Int)
+	for _, c := range []struct{ p, dP *big.Int }{{k.P, k.Dp}, {k.Q, k.Dq}} {
+		p1 := new(big.Int).Sub(c.p, one)
+		if c.dP.Cmp(p1) >= 0 {
+			return ErrInvalidKey
+		}
+		// e*dP = e*d = 1 (mod p-1)
+		for _, d := range []*big.Int{c.dP, k.D} {
+			if x.Mul(e, d).Mod(x, p1).Cmp(one) != 0 {
+				return ErrInvalidKey
+			}
+		}
+	}
+	if k.QInv.Cmp(k.P) >= 0 || x.Mul(k.QInv, k.Q).Mod(x, k.P).Cmp(one) != 0 {
+		return ErrInvalidKey
+	}
+
+	prod := new(big.Int).Mul(k.P, k.Q)
+	for _, o := range k.Others {
+		if o.R == nil || o.D == nil || o.T == nil || o.D.Sign() <= 0 || o.T.Sign() <= 0 {
+			return ErrInvalidKey
+		}
+		r1 := new(big.Int).Sub(o.R, one)
+		if r1.Sign() <= 0 || o.D.Cmp(r1) >= 0 {
+			return ErrInvalidKey
+		}
+		for _, d := range []*big.Int{o.D, k.D} {
+			if x.Mul(e, d).Mod(x, r1).Cmp(one) != 0 {
+				return ErrInvalidKey
+			}
+		}
+		if o.T.Cmp(o.R) >= 0 || x.Mul(o.T, prod).Mod(x, o.R).Cmp(one) != 0 {
+			return ErrInvalidKey
+		}
+		prod.Mul(prod, o.R)
+	}
+	if prod.Cmp(k.N) != 0 {
+		return ErrInvalidKey
+	}
 	return nil
 }
 
@@ -104,17 +202,64 @@ var (
 	e   = big.NewInt(E)
 )
 
+// PrimeGeneration selects how the secret primes of a key are generated.
+type PrimeGeneration int
+
+const (
+	// Incremental searches for the primes nearest to random starting points. The
+	// primes are probabilistically tested.
+	Incremental PrimeGeneration = iota
+
+	// Provable generates provably prime p and q with the Shawe-Taylor construction
+	// of FIPS 186-5. Their certificates are available from PrivateKey.Certificates.
+	Provable
+
+	// Uniform chooses p and q uniformly at random from the primes of the required
+	// size, avoiding the bias of Incremental towards primes following large gaps.
+	// Since every candidate is an independent uniform draw, the primes are tested with
+	// the number of rounds chosen by primes.Rounds.
+	Uniform
+)
+
+// KeyOptions configures key generation. The zero value selects the defaults.
+type KeyOptions struct {
+	Primes PrimeGeneration
+}
+
 // NewKey generates a new RSA key pair of the requested number of bits. bits must be at
-// least 8.
+// least 64.
 func NewKey(bits int) (*PrivateKey, error) {
+	return NewKeyWithOptions(bits, nil)
+}
+
+// NewKeyWithOptions is like NewKey, but generates the key as configured by opts,
+// which may be nil.
+func NewKeyWithOptions(bits int, opts *KeyOptions) (*PrivateKey, error) {
 	fail := func(err error) (*PrivateKey, error) { return nil, err }
 
 	if bits < 64 {
 		panic("crypto/rsa: bits must be at least 64")
 	}
+	if opts == nil {
+		opts = new(KeyOptions)
+	}
 
 	for {
-		p, q, n, err := genSecrets(bits)
+		var (
+			p, q, n      *big.Int
+			pCert, qCert *primes.Certificate
+			err          error
+		)
+		switch opts.Primes {
+		case Incremental:
+			p, q, n, err = genSecrets(bits)
+		case Provable:
+			p, q, n, pCert, qCert, err = genProvableSecrets(bits)
+		case Uniform:
+			p, q, n, err = genUniformSecrets(bits)
+		default:
+			panic("crypto/rsa: unknown prime generation method")
+		}
 		if err != nil {
 			return fail(err)
 		}
@@ -124,21 +269,26 @@ func NewKey(bits int) (*PrivateKey, error) {
 		q1 := new(big.Int).Sub(q, one)
 		p1q1 := new(big.Int).Mul(p1, q1)
 		d := new(big.Int).ModInverse(e, p1q1)
+		if d == nil {
+			continue // e is not coprime with (p-1)(q-1)
+		}
 
 		dP := new(big.Int).Mod(d, p1)
 		dQ := new(big.Int).Mod(d, q1)
 		qInv := new(big.Int).ModInverse(q, p)
 
 		priv := &PrivateKey{
-			n:    n,
-			e:    new(big.Int).Set(e),
-			d:    d,
-			p:    p,
-			q:    q,
-			dP:   dP,
-			dQ:   dQ,
-			qInv: qInv,
-			bits: bits,
+			n:     n,
+			e:     new(big.Int).Set(e),
+			d:     d,
+			p:     p,
+			q:     q,
+			dP:    dP,
+			dQ:    dQ,
+			qInv:  qInv,
+			bits:  bits,
+			pCert: pCert,
+			qCert: qCert,
 		}
 
 		return priv, nil
@@ -153,16 +303,27 @@ var (
 	ErrEncoding              = errors.New("crypto/rsa: encoding failure")
 	ErrDecoding              = errors.New("crypto/rsa: decoding failure")
 	ErrUnsupportedKey        = errors.New("crypto/rsa: unsupported key version")
+	ErrInvalidKey            = errors.New("crypto/rsa: invalid key")
 )
 
 // Encrypt encrypts m using the public key and masking (defined by h). p must be the
 // same value passed to Decrypt.
 func Encrypt(pub *PublicKey, h hash.Hash, m, p []byte) ([]byte, error) {
+	return EncryptWithMGF(pub, h, h, m, p)
+}
+
+// EncryptWithMGF is like Encrypt, but generates the masks with mgfHash rather than h.
+// The same hashes must be passed to DecryptWithMGF.
+func EncryptWithMGF(pub *PublicKey, h, mgfHash hash.Hash, m, p []byte) ([]byte, error) {
 	keySize := (pub.bits + 7) / 8
 	if len(m) > keySize-2*h.Size()-2 {
 		return nil, ErrMessageTooLarge
 	}
-	em, err := oaepEncode(h, m, p, keySize-1)
+	s := make([]byte, h.Size())
+	if _, err := rand.Read(s); err != nil {
+		return nil, err
+	}
+	em, err := oaepEncode(h, mgfHash, m, p, s, keySize)
 	if err != nil {
 		return nil, err
 	}
@@ -185,11 +346,34 @@ func encrypt(p *PublicKey, m *big.Int) (*big.Int, error) {
 // Decrypt decrypts c using the private key and masking (defined by h). p must be the
 // same value passed to Encrypt.
 func Decrypt(priv *PrivateKey, h hash.Hash, c, p []byte) ([]byte, error) {
+	return DecryptWithMGF(priv, h, h, c, p)
+}
+
+// DecryptWithMGF is like Decrypt, but generates the masks with mgfHash rather than h.
+func DecryptWithMGF(priv *PrivateKey, h, mgfHash hash.Hash, c, p []byte) ([]byte, error) {
 	keySize := (priv.bits + 7) / 8
 	if len(c) != keySize {
 		return nil, ErrCipherTextWrongLength
 	}
+	bc := new(big.Int).SetBytes(c)
+	if bc.Cmp(priv.n) >= 0 {
+		return nil, ErrDecryption
+	}
 
+	bm, err := decryptBlinded(priv, bc)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := oaepDecode(h, mgfHash, leftPad(bm, keySize), p)
+	if err != nil {
+		return nil, ErrDecryption
+	}
+	return m, nil
+}
+
+// decryptBlinded is like decrypt, but blinds c with a random r.
+func decryptBlinded(priv *PrivateKey, c *big.Int) (*big.Int, error) {
 	// Use blinding to stop timing attacks. Multiplying c by r^e gives
 	// c(r^e)=(m^e)(r^e) (mod n). ((m^e)(r^e))^d=m*r => m*r*rInv=m (mod n)
 	// Note: r must be coprime with N
@@ -205,22 +389,12 @@ func Decrypt(priv *PrivateKey, h hash.Hash, c, p []byte) ([]byte, error) {
 	}
 	r.Exp(r, priv.e, priv.n)
 
-	bc := new(big.Int).SetBytes(c)
-	bc.Mul(bc, r).Mod(bc, priv.n)
+	bc := new(big.Int).Mul(c, r)
+	bc.Mod(bc, priv.n)
 
 	bm := decrypt(priv, bc)
 	bm.Mul(bm, rInv).Mod(bm, priv.n)
-
-	em := bm.Bytes()
-	if len(em) < keySize-1 {
-		pad := make([]byte, keySize-len(em)-1)
-		em = append(pad, em...)
-	}
-	m, err := oaepDecode(h, em, p)
-	if err != nil {
-		return nil, ErrDecryption
-	}
-	return m, nil
+	return bm, nil
 }
 
 func decrypt(p *PrivateKey, c *big.Int) *big.Int {
@@ -233,13 +407,28 @@ func decrypt(p *PrivateKey, c *big.Int) *big.Int {
 	h.Mod(h, p.p)
 	h.Mul(h, p.q)
 	h.Add(h, m2)
+
+	// The result is extended from modulo r_1*...*r_(i-1) to modulo r_1*...*r_i with
+	// each further prime r_i, as in RFC 8017 section 5.1.2.
+	if len(p.others) > 0 {
+		r := new(big.Int).Mul(p.p, p.q)
+		for _, o := range p.others {
+			mi := new(big.Int).Exp(c, o.D, o.R)
+			mi.Sub(mi, h).Mul(mi, o.T).Mod(mi, o.R)
+			h.Add(h, mi.Mul(mi, r))
+			r.Mul(r, o.R)
+		}
+	}
 	return h
 }
 
-func oaepEncode(h hash.Hash, m, p []byte, l int) ([]byte, error) {
-	if len(m) > l-2*h.Size()-1 {
+// oaepEncode encodes m into l bytes with the seed s. The encoding starts with a zero
+// byte, so that it is less than any modulus of l bytes.
+func oaepEncode(h, mgfHash hash.Hash, m, p, s []byte, l int) ([]byte, error) {
+	if len(m) > l-2*h.Size()-2 {
 		return nil, ErrEncoding
 	}
+	l--
 
 	padLen := l - len(m) - 2*h.Size() - 1
 
@@ -255,40 +444,41 @@ func oaepEncode(h hash.Hash, m, p []byte, l int) ([]byte, error) {
 	db = append(db, 1)
 	db = append(db, m...)
 
-	s := make([]byte, h.Size())
-	if _, err := rand.Read(s); err != nil {
-		return nil, err
-	}
-
-	dbm := mgf(h, s, l-h.Size())
+	s = append([]byte(nil), s...)
+	dbm := mgf(mgfHash, s, l-h.Size())
 	mustSameLength(db, dbm)
 	for i := range db {
 		db[i] ^= dbm[i]
 	}
 
-	sm := mgf(h, db, h.Size())
+	sm := mgf(mgfHash, db, h.Size())
 	mustSameLength(s, sm)
 	for i := range s {
 		s[i] ^= sm[i]
 	}
 
-	return append(s, db...), nil
+	em := append([]byte{0}, s...)
+	return append(em, db...), nil
 }
 
-func oaepDecode(h hash.Hash, em, p []byte) ([]byte, error) {
-	if len(em) < 2*h.Size()+1 {
+// oaepDecode decodes em, which was encoded by oaepEncode, unmasking it in place. Its
+// running time depends only on the lengths of em and p and on the length of the
+// message if em is valid, and every invalid encoding fails with ErrDecoding.
+func oaepDecode(h, mgfHash hash.Hash, em, p []byte) ([]byte, error) {
+	if len(em) < 2*h.Size()+2 {
 		return nil, ErrDecoding
 	}
 
-	s, db := em[:h.Size()], em[h.Size():]
+	valid := subtle.ConstantTimeByteEq(em[0], 0)
+	s, db := em[1:1+h.Size()], em[1+h.Size():]
 
-	sm := mgf(h, db, h.Size())
+	sm := mgf(mgfHash, db, h.Size())
 	mustSameLength(s, sm)
 	for i := range s {
 		s[i] ^= sm[i]
 	}
 
-	dbm := mgf(h, s, len(em)-h.Size())
+	dbm := mgf(mgfHash, s, len(db))
 	mustSameLength(db, dbm)
 	for i := range db {
 		db[i] ^= dbm[i]
@@ -297,17 +487,25 @@ func oaepDecode(h hash.Hash, em, p []byte) ([]byte, error) {
 	h.Reset()
 	h.Write(p)
 	ps := h.Sum(nil)
+	valid &= subtle.ConstantTimeCompare(db[:len(ps)], ps)
 
-	if !bytes.HasPrefix(db, ps) {
-		return nil, ErrDecoding
+	// The hash of p is followed by zero bytes, then a one byte, then the message. The
+	// one byte is found without branching on the contents of db.
+	db = db[len(ps):]
+	lookingForOne, index, invalid := 1, 0, 0
+	for i := range db {
+		isZero := subtle.ConstantTimeByteEq(db[i], 0)
+		isOne := subtle.ConstantTimeByteEq(db[i], 1)
+		index = subtle.ConstantTimeSelect(lookingForOne&isOne, i, index)
+		lookingForOne = subtle.ConstantTimeSelect(isOne, 0, lookingForOne)
+		invalid = subtle.ConstantTimeSelect(lookingForOne&^isZero, 1, invalid)
 	}
+	valid &^= invalid | lookingForOne
 
-	db = bytes.TrimPrefix(db, ps)
-	db = bytes.TrimLeft(db, "\x00")
-	if len(db) == 0 || db[0] != 1 {
+	if valid != 1 {
 		return nil, ErrDecoding
 	}
-	return db[1:], nil
+	return db[index+1:], nil
 }
 
 func mustSameLength(a, b []byte) {
@@ -339,7 +537,9 @@ func mgf(h hash.Hash, z []byte, l int) []byte {
 
 // Generate two large primes p and q such that pq has exactly the required bits.
 func genSecrets(bits int) (p, q, n *big.Int, err error) {
-	// Key is more secure if p and q differ slightly in bit length
+	// The candidates of an incremental search are not independent, so the
+	// average-case bound of primes.Rounds does not apply and the primes are tested
+	// with Is. Key is more secure if p and q differ slightly in bit length.
 	p, err = primes.Find(bits/2+1, 128)
 	if err != nil {
 		return nil, nil, nil, err
@@ -382,3 +582,51 @@ func genSecrets(bits int) (p, q, n *big.Int, err error) {
 	n.Mul(p, q)
 	return p, q, n, nil
 }
+
+// Generate two uniformly random primes p and q such that pq has exactly the required
+// bits.
+func genUniformSecrets(bits int) (p, q, n *big.Int, err error) {
+	// Primes of a and b bits that are at least sqrt(2)*2^(a-1) and sqrt(2)*2^(b-1)
+	// have a product of exactly a+b bits.
+	rangeFor := func(b int) (lo, hi *big.Int) {
+		lo = new(big.Int).Lsh(one, uint(2*b-1))
+		lo.Sqrt(lo).Add(lo, one)
+		return lo, new(big.Int).Lsh(one, uint(b))
+	}
+
+	pLo, pHi := rangeFor(bits - bits/2)
+	qLo, qHi := rangeFor(bits / 2)
+	for {
+		p, err = primes.FindConstrainedWith(primes.AverageCase, pLo, pHi, one, one, 128)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		q, err = primes.FindConstrainedWith(primes.AverageCase, qLo, qHi, one, one, 128)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if p.Cmp(q) != 0 {
+			return p, q, new(big.Int).Mul(p, q), nil
+		}
+	}
+}
+
+// Generate two provable primes p and q such that pq has exactly the required bits.
+func genProvableSecrets(bits int) (p, q, n *big.Int, pCert, qCert *primes.Certificate, err error) {
+	for {
+		p, pCert, err = primes.FindProvable(bits - bits/2)
+		if err != nil {
+			return nil, nil, nil, nil, nil, err
+		}
+		q, qCert, err = primes.FindProvable(bits / 2)
+		if err != nil {
+			return nil, nil, nil, nil, nil, err
+		}
+
+		// The product of two primes of a and b bits has a+b-1 or a+b bits.
+		n = new(big.Int).Mul(p, q)
+		if n.BitLen() == bits && p.Cmp(q) != 0 {
+			return p, q, n, pCert, qCert, nil
+		}
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
+	"encoding/asn1"
 	"math/big"
 	"testing"
 
+	"github.com/mmussomele/crypto/primes"
 	"github.com/mmussomele/crypto/rand"
 )
 
@@ -110,6 +112,36 @@ func TestEncryption(t *testing.T) {
 	}
 }
 
+func TestDecryptLeadingByte(t *testing.T) {
+	priv, err := NewKey(1024)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	h := sha256.New()
+	seed := make([]byte, h.Size())
+	em, err := oaepEncode(h, h, []byte("message"), nil, seed, 128)
+	if err != nil {
+		t.Fatalf("Failed to encode test message: %v", err)
+	}
+
+	// An otherwise valid encoding with a non-zero first byte fails like any other
+	// invalid encoding.
+	for _, b := range []byte{0, 1, 0x7f} {
+		em[0] = b
+		c, err := encrypt(priv.PublicKey(), new(big.Int).SetBytes(em))
+		if err != nil {
+			t.Fatalf("Failed to encrypt test message: %v", err)
+		}
+		m, err := Decrypt(priv, h, leftPad(c, 128), nil)
+		switch {
+		case b == 0 && (err != nil || string(m) != "message"):
+			t.Fatalf("Failed to decrypt test message: %v", err)
+		case b != 0 && err != ErrDecryption:
+			t.Fatalf("Expected ErrDecryption for first byte %#x, got %v", b, err)
+		}
+	}
+}
+
 func TestOAEP(t *testing.T) {
 	h := sha256.New()
 
@@ -134,7 +166,12 @@ func TestOAEP(t *testing.T) {
 				t.Fatalf("Failed to generate test p: %v", err)
 			}
 
-			enc, err := oaepEncode(h, b, p, size+3*h.Size())
+			seed := make([]byte, h.Size())
+			if _, err := rand.Read(seed); err != nil {
+				t.Fatalf("Failed to generate test seed: %v", err)
+			}
+
+			enc, err := oaepEncode(h, h, b, p, seed, size+3*h.Size())
 			switch {
 			case err != nil:
 				t.Fatalf("Failed to encode test message: %v", err)
@@ -142,7 +179,7 @@ func TestOAEP(t *testing.T) {
 				t.Fatal("Encoded message matched original")
 			}
 
-			dec, err := oaepDecode(h, enc, p)
+			dec, err := oaepDecode(h, h, enc, p)
 			switch {
 			case err != nil:
 				t.Fatalf("Failed to decode test message: %v", err)
@@ -153,7 +190,7 @@ func TestOAEP(t *testing.T) {
 			}
 
 			p[0]++
-			dec, err = oaepDecode(h, enc, p)
+			dec, err = oaepDecode(h, h, enc, p)
 			if err == nil {
 				t.Fatal("Succeeded decoding with wrong p")
 			}
@@ -161,6 +198,82 @@ func TestOAEP(t *testing.T) {
 	}
 }
 
+func TestUniformKey(t *testing.T) {
+	for _, size := range []int{768, 1029, 2048} {
+		priv, err := NewKeyWithOptions(size, &KeyOptions{Primes: Uniform})
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		if bits := priv.n.BitLen(); bits != size {
+			t.Fatalf("Expected %d bit modulus, got %d", size, bits)
+		}
+		if bits := priv.p.BitLen(); bits != size-size/2 {
+			t.Fatalf("Expected %d bit p, got %d", size-size/2, bits)
+		}
+
+		h := sha256.New()
+		m := []byte("uniformly random")
+		c, err := Encrypt(priv.PublicKey(), h, m, nil)
+		if err != nil {
+			t.Fatalf("Failed to encrypt test message: %v", err)
+		}
+		d, err := Decrypt(priv, h, c, nil)
+		switch {
+		case err != nil:
+			t.Fatalf("Failed to decrypt test message: %v", err)
+		case !bytes.Equal(m, d):
+			t.Fatal("Decrypted message did not match original")
+		}
+	}
+}
+
+func TestProvableKey(t *testing.T) {
+	for _, size := range []int{768, 1029, 2048} {
+		priv, err := NewKeyWithOptions(size, &KeyOptions{Primes: Provable})
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		if bits := priv.n.BitLen(); bits != size {
+			t.Fatalf("Expected %d bit modulus, got %d", size, bits)
+		}
+
+		pCert, qCert := priv.Certificates()
+		switch {
+		case pCert == nil || qCert == nil:
+			t.Fatal("Missing primality certificates")
+		case pCert.N.Cmp(priv.p) != 0 || qCert.N.Cmp(priv.q) != 0:
+			t.Fatal("Certificates do not match the key's primes")
+		}
+		for _, c := range []*primes.Certificate{pCert, qCert} {
+			if err := primes.VerifyCertificate(c); err != nil {
+				t.Fatalf("Failed to verify certificate: %v", err)
+			}
+		}
+
+		h := sha256.New()
+		m := []byte("provably prime")
+		c, err := Encrypt(priv.PublicKey(), h, m, nil)
+		if err != nil {
+			t.Fatalf("Failed to encrypt test message: %v", err)
+		}
+		d, err := Decrypt(priv, h, c, nil)
+		switch {
+		case err != nil:
+			t.Fatalf("Failed to decrypt test message: %v", err)
+		case !bytes.Equal(m, d):
+			t.Fatal("Decrypted message did not match original")
+		}
+	}
+
+	priv, err := NewKey(512)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	if pCert, qCert := priv.Certificates(); pCert != nil || qCert != nil {
+		t.Fatal("Unexpected certificates for incrementally generated key")
+	}
+}
+
 func TestCompatible(t *testing.T) {
 	priv, err := NewKey(1024)
 	if err != nil {
@@ -203,6 +316,52 @@ func TestCompatible(t *testing.T) {
 	mustEq(t, goPriv.Precomputed.Qinv, key.qInv)
 }
 
+func TestUnmarshalInvalid(t *testing.T) {
+	priv, err := NewKey(512)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	for _, edit := range []func(k *asnPrivateKey){
+		func(k *asnPrivateKey) { k.N = new(big.Int).Add(k.N, one) },
+		func(k *asnPrivateKey) { k.N = new(big.Int).Neg(k.N) },
+		func(k *asnPrivateKey) { k.E = 1 },
+		func(k *asnPrivateKey) { k.E = 3 },
+		func(k *asnPrivateKey) { k.D = new(big.Int).Add(k.D, one) },
+		func(k *asnPrivateKey) { k.P = one },
+		func(k *asnPrivateKey) { k.Dp = big.NewInt(0) },
+		func(k *asnPrivateKey) { k.Dq = new(big.Int).Add(k.Dq, k.Q) },
+		func(k *asnPrivateKey) { k.QInv = new(big.Int).Add(k.QInv, k.P) },
+		func(k *asnPrivateKey) { k.Version = 1 },
+		func(k *asnPrivateKey) { k.Others = []crtPrime{{R: big.NewInt(3), D: one, T: one}} },
+	} {
+		k := asnPrivateKey{N: priv.n, E: E, D: priv.d, P: priv.p, Q: priv.q, Dp: priv.dP, Dq: priv.dQ, QInv: priv.qInv}
+		edit(&k)
+		b, err := asn1.Marshal(k)
+		if err != nil {
+			t.Fatalf("Failed to encode key: %v", err)
+		}
+		if err := new(PrivateKey).Unmarshal(b); err != ErrInvalidKey {
+			t.Fatalf("Expected ErrInvalidKey for %+v, got %v", k, err)
+		}
+	}
+
+	b, err := asn1.Marshal(asnPrivateKey{Version: 2, N: priv.n, E: E, D: priv.d, P: priv.p, Q: priv.q, Dp: priv.dP, Dq: priv.dQ, QInv: priv.qInv})
+	if err != nil {
+		t.Fatalf("Failed to encode key: %v", err)
+	}
+	if err := new(PrivateKey).Unmarshal(b); err != ErrUnsupportedKey {
+		t.Fatalf("Expected ErrUnsupportedKey, got %v", err)
+	}
+
+	b = priv.Marshal()
+	for _, b := range [][]byte{nil, b[:len(b)-1], append(b, 0)} {
+		if err := new(PrivateKey).Unmarshal(b); err == nil {
+			t.Fatal("Parsed a malformed key")
+		}
+	}
+}
+
 func mustEq(t *testing.T, a, b *big.Int) {
 	t.Helper()
 	if a.Cmp(b) != 0 {